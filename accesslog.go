@@ -0,0 +1,134 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The format of the time in the access log.
+const accessLogTime = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogger writes a line for each request that a handler serves to a log,
+// in the Combined Log Format followed by the time taken in seconds.
+type accessLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+
+	handler http.Handler
+}
+
+func (l *accessLogger) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	start := time.Now()
+	lrw := &loggedResponse{ResponseWriter: rw}
+	l.handler.ServeHTTP(lrw, req)
+	l.log(req, lrw, start, time.Since(start))
+}
+
+// log writes the line for |req|, to which |rw| was the response.
+func (l *accessLogger) log(req *http.Request, rw *loggedResponse, start time.Time, duration time.Duration) {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	user := "-"
+	if name, _, ok := req.BasicAuth(); ok && name != "" {
+		user = logEscape(name)
+	}
+	uri := req.RequestURI
+	if uri == "" {
+		uri = req.URL.RequestURI()
+	}
+	size := "-"
+	if rw.bytes > 0 {
+		size = fmt.Sprint(rw.bytes)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.w, "%s - %s [%s] \"%s %s %s\" %d %s \"%s\" \"%s\" %.3f\n",
+		host, user, start.Format(accessLogTime),
+		logEscape(req.Method), logEscape(uri), logEscape(req.Proto),
+		rw.statusCode(), size,
+		logField(req.Referer()), logField(req.UserAgent()),
+		duration.Seconds())
+}
+
+// logField returns |s| escaped for the log, or "-" if it is empty.
+func logField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return logEscape(s)
+}
+
+// logEscape escapes the quotes, backslashes and control characters in |s|, so
+// that it cannot break the format of the log.
+func logEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// loggedResponse records the status and size of a response for the log.
+type loggedResponse struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *loggedResponse) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *loggedResponse) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Flush supports the event streams of the reloader.
+func (r *loggedResponse) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap allows http.ResponseController to reach the underlying response.
+func (r *loggedResponse) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// statusCode returns the status of the response, which is OK if the handler
+// did not write anything.
+func (r *loggedResponse) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
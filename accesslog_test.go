@@ -0,0 +1,52 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	var log bytes.Buffer
+	logger := &accessLogger{
+		w: &log,
+		handler: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/missing" {
+				http.NotFound(rw, req)
+				return
+			}
+			rw.Write([]byte("Hello"))
+		}),
+	}
+
+	req := httptest.NewRequest("GET", "/post.html?x=1", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("Referer", "https://example.com/")
+	req.Header.Set("User-Agent", `Agent "quoted"`)
+	logger.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("HEAD", "/missing", nil)
+	req.RemoteAddr = "192.0.2.2:1234"
+	logger.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := bytes.Split(bytes.TrimSpace(log.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %q", log.String())
+	}
+	expectations := []*regexp.Regexp{
+		regexp.MustCompile(`^192\.0\.2\.1 - - \[\d\d/\w{3}/\d{4}:\d\d:\d\d:\d\d [+-]\d{4}\] "GET /post\.html\?x=1 HTTP/1\.1" 200 5 "https://example\.com/" "Agent \\"quoted\\"" \d+\.\d{3}$`),
+		regexp.MustCompile(`^192\.0\.2\.2 - - \[.+\] "HEAD /missing HTTP/1\.1" 404 \d+ "-" "-" \d+\.\d{3}$`),
+	}
+	for i, re := range expectations {
+		if !re.Match(lines[i]) {
+			t.Errorf("Line %d does not match %v: %q", i, re, lines[i])
+		}
+	}
+}
@@ -18,12 +18,10 @@
 package main
 
 import (
-	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path"
-	"runtime"
 	"text/template"
 	"time"
 )
@@ -31,6 +29,7 @@ import (
 var (
 	// Flags that allow overriding configuration defaults.
 	serverPort = flag.Int("port", 0, "Override the port on which the standalone HTTP server will run.")
+	listenAddr = flag.String("listen", "", "Override the address on which the standalone HTTP server listens, such as 127.0.0.1 to only accept local connections.")
 	outputDir  = flag.String("output", "", "Override the output directory when rendering to static files.")
 
 	commandDocs = map[string]string{
@@ -73,12 +72,8 @@ func main() {
 		os.Exit(2)
 	}
 
-	// Process flags to override configuration values.
-	if *serverPort != 0 {
-		blog.config.Port = *serverPort
-	}
-	if *outputDir != "" {
-		blog.config.OutputDir = *outputDir
+	if blog != nil {
+		applyFlags(blog)
 	}
 
 	// Execute the specified command.
@@ -105,6 +100,19 @@ func main() {
 	}
 }
 
+// applyFlags processes the flags that override configuration values of |blog|.
+func applyFlags(blog *Blog) {
+	if *serverPort != 0 {
+		blog.config.Port = *serverPort
+	}
+	if *listenAddr != "" {
+		blog.config.Listen = *listenAddr
+	}
+	if *outputDir != "" {
+		blog.config.OutputDir = *outputDir
+	}
+}
+
 func usage() {
 	fmt.Fprintf(os.Stderr, "Usage: %s command [path/to/blog]:\n\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "  Commands:\n")
@@ -127,17 +135,10 @@ func newBlog(at string) error {
 		return err
 	}
 
-	_, thisFile, _, ok := runtime.Caller(0)
-	if !ok {
-		return errors.New("cannot find installation directory")
-	}
-
 	data := struct {
-		InstallDir string
-		Date       time.Time
+		Date time.Time
 	}{
-		InstallDir: path.Dir(thisFile),
-		Date:       time.Now(),
+		Date: time.Now(),
 	}
 
 	config, err := template.New("config").Parse(defaultConfig)
@@ -173,8 +174,6 @@ var (
 	"Title": "A Black Blog",
 	"URL": "https://example.com/blog/",
 	"PostsDir": "./posts",
-	"TemplatesDir": "{{.InstallDir}}/templates",
-	"StaticFilesDir": "{{.InstallDir}}/templates/static",
 	"OutputDir": "./out",
 	"Port": 8066
 }`
@@ -20,9 +20,13 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
 	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/russross/blackfriday/v2"
 	"github.com/yuin/goldmark"
@@ -49,6 +53,12 @@ type Blog struct {
 
 	// Path to the configuration file (including "blackblog.json").
 	configPath string
+
+	// The modification time of the configuration file when it was read.
+	modTime time.Time
+
+	// The templates, which are parsed once and shared by every page.
+	tpl templateSet
 }
 
 const configVersion = 2
@@ -72,13 +82,53 @@ type configFile struct {
 	// mode to support the templates.
 	StaticFilesDir string
 
+	// Path to a theme directory, which contains templates and a "static"
+	// directory of static files. Templates and static files that are not in the
+	// TemplatesDir or the StaticFilesDir are looked up in the theme, and then in
+	// the built-in defaults.
+	Theme string
+
 	// When rendering the blog to static files, the directory to place the
 	// output.
 	OutputDir string
 
+	// Files in the OutputDir that were produced by an earlier render but are no
+	// longer produced are removed when rendering, unless they match one of these
+	// patterns. Patterns are relative to the OutputDir, use the syntax of
+	// path.Match, and match all the files in a matching directory.
+	Preserve []string
+
+	// When rendering, also write gzip (.gz) and Brotli (.br) compressed copies
+	// of the HTML, XML and CSS files in the OutputDir, for web servers that
+	// serve precompressed files.
+	Precompress bool
+
 	// When running as a server, the port on which the server is bound.
 	Port int
 
+	// When running as a server, the host or IP address on which the server is
+	// bound, such as "127.0.0.1". If empty, the server listens on all
+	// interfaces. This may also include a port, which overrides the Port.
+	Listen string
+
+	// When running as a server, the paths to the TLS certificate and private key
+	// files. If set, the server is served over HTTPS.
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// When running as a server, the path to a file to which a line is appended
+	// for each request, in the Combined Log Format followed by the time taken
+	// in seconds. If empty, requests are not logged.
+	AccessLog string
+
+	// When running as a server, expose the request counts and render times at
+	// /debug/metrics, in the Prometheus text format.
+	Metrics bool
+
+	// The number of posts listed on each page of the index. If zero, all the
+	// posts are listed on a single page.
+	PostsPerPage int
+
 	// A list of string EXTENSION_ constants to pass to Blackfriday Markdown.
 	MarkdownExtensions []string
 
@@ -119,10 +169,55 @@ func (b *Blog) URL() string {
 	return b.config.URL
 }
 
+// AbsoluteURL returns the full URL for a path relative to the blog root.
+func (b *Blog) AbsoluteURL(rel string) string {
+	base := b.URL()
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	return base + rel
+}
+
 func (b *Blog) Port() int {
 	return b.config.Port
 }
 
+// ListenAddr returns the address on which the server listens, which is the
+// Listen address and the Port.
+func (b *Blog) ListenAddr() string {
+	if _, _, err := net.SplitHostPort(b.config.Listen); err == nil {
+		return b.config.Listen
+	}
+	return net.JoinHostPort(b.config.Listen, strconv.Itoa(b.Port()))
+}
+
+// TLSFiles returns the paths to the TLS certificate and key files, which are
+// empty if the server does not use TLS.
+func (b *Blog) TLSFiles() (cert, key string) {
+	if b.config.TLSCertFile != "" {
+		cert = b.resolvePath(b.config.TLSCertFile)
+	}
+	if b.config.TLSKeyFile != "" {
+		key = b.resolvePath(b.config.TLSKeyFile)
+	}
+	return cert, key
+}
+
+func (b *Blog) AccessLog() string {
+	if b.config.AccessLog == "" {
+		return ""
+	}
+	return b.resolvePath(b.config.AccessLog)
+}
+
+func (b *Blog) PostsPerPage() int {
+	return b.config.PostsPerPage
+}
+
+func (b *Blog) Precompress() bool {
+	return b.config.Precompress
+}
+
 func (b *Blog) TemplatesDir() string {
 	return b.getPath(b.config.TemplatesDir)
 }
@@ -134,6 +229,13 @@ func (b *Blog) StaticFilesDir() string {
 	return b.getPath(b.config.StaticFilesDir)
 }
 
+func (b *Blog) ThemeDir() string {
+	if b.config.Theme == "" {
+		return ""
+	}
+	return b.getPath(b.config.Theme)
+}
+
 func (b *Blog) GetPostsDir() string {
 	return b.getPath(b.config.PostsDir)
 }
@@ -142,10 +244,32 @@ func (b *Blog) GetOutputDir() string {
 	return b.getPath(b.config.OutputDir)
 }
 
+// isPreserved returns true if the path, relative to the OutputDir, matches
+// one of the Preserve patterns.
+func (b *Blog) isPreserved(rel string) bool {
+	for _, pattern := range b.config.Preserve {
+		for p := rel; p != "." && p != "/"; p = path.Dir(p) {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (b *Blog) getPath(part string) string {
 	return path.Join(path.Dir(b.configPath), part)
 }
 
+// resolvePath returns |part| if it is an absolute path, or else the path of
+// |part| relative to the directory of the configuration file.
+func (b *Blog) resolvePath(part string) string {
+	if filepath.IsAbs(part) {
+		return part
+	}
+	return b.getPath(part)
+}
+
 func (b *Blog) GetMarkdownExtensions() blackfriday.Extensions {
 	return b.markdownExtensions
 }
@@ -167,6 +291,11 @@ func ReadBlog(p string) (*Blog, error) {
 	}
 	defer f.Close()
 
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
 	d := json.NewDecoder(f)
 	var config configFile
 	if err = d.Decode(&config); err != nil {
@@ -180,6 +309,7 @@ func ReadBlog(p string) (*Blog, error) {
 	blog := &Blog{
 		config:     config,
 		configPath: path.Clean(p),
+		modTime:    info.ModTime(),
 	}
 	if err := blog.parseOptions(); err != nil {
 		return nil, err
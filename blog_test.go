@@ -93,6 +93,55 @@ func TestGetPaths(t *testing.T) {
 	}
 }
 
+func TestTLSFiles(t *testing.T) {
+	blog := &Blog{
+		configPath: "/abs/path/blackblog.json",
+		config: configFile{
+			TLSCertFile: "/etc/ssl/cert.pem",
+			TLSKeyFile:  "./key.pem",
+		},
+	}
+	cert, key := blog.TLSFiles()
+	if e := "/etc/ssl/cert.pem"; cert != e {
+		t.Errorf("TLSFiles() should return the absolute cert path %q, got %q", e, cert)
+	}
+	if e := "/abs/path/key.pem"; key != e {
+		t.Errorf("TLSFiles() should return the key path %q, got %q", e, key)
+	}
+}
+
+func TestAccessLogPath(t *testing.T) {
+	for _, test := range []struct{ config, path string }{
+		{"", ""},
+		{"/var/log/blackblog.log", "/var/log/blackblog.log"},
+		{"./access.log", "/abs/path/access.log"},
+	} {
+		blog := &Blog{configPath: "/abs/path/blackblog.json", config: configFile{AccessLog: test.config}}
+		if got := blog.AccessLog(); got != test.path {
+			t.Errorf("AccessLog() for %q should return %q, got %q", test.config, test.path, got)
+		}
+	}
+}
+
+func TestListenAddr(t *testing.T) {
+	tests := []struct {
+		listen string
+		port   int
+		addr   string
+	}{
+		{"", 8066, ":8066"},
+		{"127.0.0.1", 8066, "127.0.0.1:8066"},
+		{"::1", 8066, "[::1]:8066"},
+		{"localhost:9000", 8066, "localhost:9000"},
+	}
+	for _, test := range tests {
+		blog := &Blog{config: configFile{Listen: test.listen, Port: test.port}}
+		if got := blog.ListenAddr(); got != test.addr {
+			t.Errorf("ListenAddr() for %q and port %d should return %q, got %q", test.listen, test.port, test.addr, got)
+		}
+	}
+}
+
 func TestExtensionsAndOptions(t *testing.T) {
 	blog := &Blog{config: configFile{
 		MarkdownExtensions:  []string{"EXTENSION_FOOTNOTES", "EXTENSION_NO_INTRA_EMPHASIS"},
@@ -0,0 +1,143 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// renderCache holds the rendered contents of the render nodes that the server
+// has served, so that they are not rendered again for every request. It also
+// holds the static files that the server has compressed.
+type renderCache struct {
+	mu sync.Mutex
+
+	// The pages, by their *render or by the name of the static file.
+	pages map[interface{}]*renderedPage
+
+	// The key with which each URL path was last rendered, and when it changed
+	// to that key. These are kept when the cache is cleared.
+	changed map[string]keyChange
+}
+
+// keyChange records when the page at a URL path was first rendered with a key.
+type keyChange struct {
+	key string
+	at  time.Time
+}
+
+// renderedPage is the rendered contents of a render node or a static file.
+type renderedPage struct {
+	// Identifies the inputs that the page was rendered from.
+	key string
+
+	content []byte
+
+	// The latest modification time of the inputs, or for pages that aggregate
+	// posts, the time at which their key last changed.
+	modTime time.Time
+
+	// The compressed contents, by the name of the encoding.
+	mu      sync.Mutex
+	encoded map[string][]byte
+}
+
+// encode returns the contents of the page compressed with |enc|, which are
+// only compressed once, at the level that is fast enough for responses.
+func (p *renderedPage) encode(enc *contentEncoding) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if content, ok := p.encoded[enc.name]; ok {
+		return content, nil
+	}
+	content, err := enc.compress(p.content, enc.fastLevel)
+	if err != nil {
+		return nil, err
+	}
+	if p.encoded == nil {
+		p.encoded = make(map[string][]byte)
+	}
+	p.encoded[enc.name] = content
+	return content, nil
+}
+
+func newRenderCache() *renderCache {
+	return &renderCache{
+		pages:   make(map[interface{}]*renderedPage),
+		changed: make(map[string]keyChange),
+	}
+}
+
+// get returns the cached page for |r|, which is a *render or the name of a
+// static file, if it was rendered with |key|.
+func (c *renderCache) get(r interface{}, key string) (*renderedPage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	page, ok := c.pages[r]
+	if !ok || page.key != key {
+		return nil, false
+	}
+	return page, true
+}
+
+// put caches the |page| rendered for |r|.
+func (c *renderCache) put(r interface{}, page *renderedPage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pages[r] = page
+}
+
+// changedAt returns the time since when the page at the URL path |name| has been
+// rendered with |key|, which is now if it was last rendered with another key.
+func (c *renderCache) changedAt(name, key string) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if change, ok := c.changed[name]; ok && change.key == key {
+		return change.at
+	}
+	now := time.Now()
+	c.changed[name] = keyChange{key: key, at: now}
+	return now
+}
+
+// clear removes every page from the cache.
+func (c *renderCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pages = make(map[interface{}]*renderedPage)
+}
+
+// renderCacheKey returns the key for the contents of |r| rendered for |blog|,
+// which covers the configuration file, the templates and the inputs of the
+// render, like the page number of an index and the checksums of its posts. It
+// also returns the latest modification time of the configuration, templates
+// and posts, which is the modification time of the page for a post. For the
+// pages that aggregate posts, which also change when a post is removed, the
+// server instead uses the time at which the key last changed.
+func renderCacheKey(blog *Blog, r *render) (string, time.Time, error) {
+	t, err := blog.templates()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	digest := md5.New()
+	fmt.Fprintln(digest, blog.configPath, blog.modTime.UnixNano(), t.fingerprint)
+	writeRenderInputs(digest, r)
+	modTime := blog.modTime
+	if t.modTime.After(modTime) {
+		modTime = t.modTime
+	}
+	for _, post := range renderInputs(r) {
+		if post.modTime.After(modTime) {
+			modTime = post.modTime
+		}
+	}
+	return hex.EncodeToString(digest.Sum(nil)), modTime, nil
+}
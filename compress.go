@@ -0,0 +1,145 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// contentEncoding is a compression that is applied to the HTML, XML and CSS
+// files of the blog.
+type contentEncoding struct {
+	// The name of the encoding in the Accept-Encoding and Content-Encoding
+	// headers.
+	name string
+
+	// The extension of the precompressed copy of a file.
+	ext string
+
+	// Returns a writer that compresses to |w| at |level|.
+	newWriter func(w io.Writer, level int) io.WriteCloser
+
+	// The level at which the server compresses its responses, which is quick
+	// enough to do for each request, and the level at which files are
+	// precompressed when rendering.
+	fastLevel, bestLevel int
+}
+
+// contentEncodings are the supported encodings, in order of preference.
+var contentEncodings = []*contentEncoding{
+	{
+		name: "br",
+		ext:  ".br",
+		newWriter: func(w io.Writer, level int) io.WriteCloser {
+			return brotli.NewWriterLevel(w, level)
+		},
+		fastLevel: 5,
+		bestLevel: brotli.BestCompression,
+	},
+	{
+		name: "gzip",
+		ext:  ".gz",
+		newWriter: func(w io.Writer, level int) io.WriteCloser {
+			zw, _ := gzip.NewWriterLevel(w, level)
+			return zw
+		},
+		fastLevel: gzip.DefaultCompression,
+		bestLevel: gzip.BestCompression,
+	},
+}
+
+// compress returns |content| compressed with the encoding at |level|.
+func (e *contentEncoding) compress(content []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	w := e.newWriter(&buf, level)
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// isCompressible returns true if the file |name| is compressed, based on its
+// extension.
+func isCompressible(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".html", ".xml", ".css":
+		return true
+	}
+	return false
+}
+
+// precompressedPaths returns the paths of the precompressed copies of the file
+// at |p|, which are written next to it when rendering if the blog is configured
+// to Precompress its files. The |blog| may be nil, in which case there are none.
+func precompressedPaths(blog *Blog, p string) []string {
+	if blog == nil || !blog.Precompress() || !isCompressible(p) {
+		return nil
+	}
+	var paths []string
+	for _, enc := range contentEncodings {
+		paths = append(paths, p+enc.ext)
+	}
+	return paths
+}
+
+// writePrecompressed writes the precompressed copies of the file at |p|, whose
+// contents are |content|, and records them in the manifest with |key|.
+func writePrecompressed(blog *Blog, p string, content []byte, manifest *buildManifest, key string) error {
+	for i, cp := range precompressedPaths(blog, p) {
+		enc := contentEncodings[i]
+		compressed, err := enc.compress(content, enc.bestLevel)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(cp, compressed, 0644); err != nil {
+			return err
+		}
+		manifest.record(cp, key)
+	}
+	return nil
+}
+
+// negotiateEncoding returns the preferred encoding that is acceptable according
+// to the Accept-Encoding header |accept|, or nil if the content should not be
+// compressed.
+func negotiateEncoding(accept string) *contentEncoding {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		ok := true
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				ok = err == nil && q > 0
+			}
+		}
+		accepted[name] = ok
+	}
+
+	for _, enc := range contentEncodings {
+		ok, listed := accepted[enc.name]
+		if !listed {
+			ok = accepted["*"]
+		}
+		if ok {
+			return enc
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// decompress returns the |content| decompressed with the encoding |name|.
+func decompress(t *testing.T, name string, content []byte) string {
+	t.Helper()
+	var r io.Reader
+	switch name {
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(content))
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	default:
+		t.Fatalf("Unknown encoding %q", name)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Error decompressing %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                   "",
+		"identity":           "",
+		"gzip":               "gzip",
+		"gzip, deflate, br":  "br",
+		"br;q=0, gzip;q=0.5": "gzip",
+		"BR":                 "br",
+		"*":                  "br",
+		"*, br;q=0":          "gzip",
+		"gzip;q=0, br;q=0.0": "",
+	}
+	for accept, want := range tests {
+		got := ""
+		if enc := negotiateEncoding(accept); enc != nil {
+			got = enc.name
+		}
+		if want != got {
+			t.Errorf("Accept-Encoding %q: expected %q, got %q", accept, want, got)
+		}
+	}
+}
+
+func TestPrecompress(t *testing.T) {
+	blog := createTestBlog(t)
+	blog.config.Precompress = true
+	out := blog.GetOutputDir()
+
+	if err := WriteStaticBlog(blog); err != nil {
+		t.Fatalf("Unexpected error rendering blog: %v", err)
+	}
+	for _, f := range []string{"index.html", "feed.xml", "static/blackblog.css"} {
+		want, err := os.ReadFile(filepath.Join(out, f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, enc := range contentEncodings {
+			data, err := os.ReadFile(filepath.Join(out, f+enc.ext))
+			if err != nil {
+				t.Errorf("Expected %s copy of %s: %v", enc.name, f, err)
+				continue
+			}
+			if got := decompress(t, enc.name, data); string(want) != got {
+				t.Errorf("%s copy of %s does not match: %q", enc.name, f, got)
+			}
+		}
+	}
+
+	// The compressed copies are removed when the option is turned off.
+	blog.config.Precompress = false
+	if err := WriteStaticBlog(blog); err != nil {
+		t.Fatalf("Unexpected error rendering blog: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "index.html.gz")); err == nil {
+		t.Errorf("Compressed copy should have been removed")
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	demarcYAML = "---\n"
+	demarcTOML = "+++\n"
+)
+
+// frontmatterFormats maps the line that opens and closes a frontmatter block to
+// the function that decodes it.
+var frontmatterFormats = map[string]func(*Post, []byte) error{
+	demarcYAML: (*Post).parseYAMLFrontmatter,
+	demarcTOML: (*Post).parseTOMLFrontmatter,
+}
+
+// parseFrontmatter decodes the frontmatter block that was delimited by the
+// |demarc| line.
+func (p *Post) parseFrontmatter(demarc string, data []byte) error {
+	return frontmatterFormats[demarc](p, data)
+}
+
+// The frontmatter starts on the second line of the file, after the opening
+// demarcation line. Decoders are given the data with this many blank lines
+// prepended, so that the line numbers they report match the file.
+const frontmatterLineOffset = 1
+
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+func (p *Post) parseYAMLFrontmatter(data []byte) error {
+	data = append(bytes.Repeat([]byte("\n"), frontmatterLineOffset), data...)
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			return p.frontmatterError(line, errors.New(m[2]))
+		}
+		return fmt.Errorf("%s: %v", p.Filename, err)
+	}
+
+	// Empty frontmatter.
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	m := doc.Content[0]
+	if m.Kind != yaml.MappingNode {
+		return p.frontmatterError(m.Line, errors.New("frontmatter must be a map of keys to values"))
+	}
+
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		key, value := m.Content[i], m.Content[i+1]
+
+		var v interface{}
+		if value.Kind == yaml.ScalarNode && value.Tag == "!!timestamp" {
+			// Keep dates as they were written, rather than as a time.Time.
+			v = value.Value
+		} else if err := value.Decode(&v); err != nil {
+			return p.frontmatterError(value.Line, err)
+		}
+
+		if err := p.setFrontmatter(key.Value, v); err != nil {
+			return p.frontmatterError(key.Line, err)
+		}
+	}
+	return nil
+}
+
+func (p *Post) parseTOMLFrontmatter(data []byte) error {
+	data = append(bytes.Repeat([]byte("\n"), frontmatterLineOffset), data...)
+
+	var values map[string]interface{}
+	if _, err := toml.Decode(string(data), &values); err != nil {
+		var perr toml.ParseError
+		if errors.As(err, &perr) {
+			return p.frontmatterError(perr.Position.Line, errors.New(perr.Message))
+		}
+		return fmt.Errorf("%s: %v", p.Filename, err)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := p.setFrontmatter(key, values[key]); err != nil {
+			return p.frontmatterError(tomlKeyLine(data, key), err)
+		}
+	}
+	return nil
+}
+
+// tomlKeyLine returns the line of the TOML |data| on which the top-level |key|
+// is set, since the decoder does not report the positions of keys. This is
+// zero if the line is not found.
+func tomlKeyLine(data []byte, key string) int {
+	re := regexp.MustCompile(`^\s*(` + regexp.QuoteMeta(key) + `|"` + regexp.QuoteMeta(key) + `")\s*=`)
+	for i, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "[") {
+			break
+		}
+		if re.MatchString(line) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (p *Post) frontmatterError(line int, err error) error {
+	return fmt.Errorf("%s:%d: %v", p.Filename, line, err)
+}
+
+// setFrontmatter sets the metadata for |key| from a decoded frontmatter value.
+func (p *Post) setFrontmatter(key string, val interface{}) error {
+	key = strings.ToLower(key)
+	switch key {
+	case "title", "url", "date", "draft", "summary":
+		switch v := val.(type) {
+		case string:
+			p.setMetadata(key, v)
+		case bool, int, int64, float64:
+			p.setMetadata(key, fmt.Sprint(v))
+		case time.Time:
+			p.setMetadata(key, v.Format(time.RFC3339))
+		default:
+			return fmt.Errorf("%q must be a single value", key)
+		}
+	case "tags":
+		switch v := val.(type) {
+		case string:
+			p.setMetadata(key, v)
+		case []interface{}:
+			for _, item := range v {
+				tag, ok := item.(string)
+				if !ok {
+					return fmt.Errorf("%q must be a list of strings", key)
+				}
+				if tag = strings.TrimSpace(tag); tag != "" {
+					p.Tags = append(p.Tags, tag)
+				}
+			}
+		default:
+			return fmt.Errorf("%q must be a list of strings", key)
+		}
+	default:
+		p.setParam(key, val)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeTestPost(t *testing.T, contents string) string {
+	p := filepath.Join(t.TempDir(), "post.md")
+	if err := os.WriteFile(p, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestYAMLFrontmatter(t *testing.T) {
+	p := writeTestPost(t, `---
+Title: "Colons: they work"
+date: 2024-02-11
+tags: [go, yaml]
+draft: true
+abstract: >
+  A value that spans
+  multiple lines.
+author:
+  name: Inigo Montoya
+  url: https://example.com
+---
+The post.
+`)
+	post, err := NewPostFromPath(p)
+	if err != nil {
+		t.Fatalf("Unexpected error parsing post: %v", err)
+	}
+	if want, got := "Colons: they work", post.Title; want != got {
+		t.Errorf("Expected title %q, got %q", want, got)
+	}
+	if want, got := "2024-02-11", post.Date; want != got {
+		t.Errorf("Expected date %q, got %q", want, got)
+	}
+	if want, got := []string{"go", "yaml"}, post.Tags; !reflect.DeepEqual(want, got) {
+		t.Errorf("Expected tags %q, got %q", want, got)
+	}
+	if !post.Draft {
+		t.Errorf("Expected post to be a draft")
+	}
+
+	params := map[string]interface{}{
+		"abstract": "A value that spans multiple lines.\n",
+		"author": map[string]interface{}{
+			"name": "Inigo Montoya",
+			"url":  "https://example.com",
+		},
+	}
+	if !reflect.DeepEqual(params, post.Params) {
+		t.Errorf("Expected params %v, got %v", params, post.Params)
+	}
+
+	contents, err := post.GetContents()
+	if err != nil {
+		t.Errorf("Failed to get post contents: %v", err)
+	}
+	if want, got := "The post.\n", string(contents); want != got {
+		t.Errorf("Wanted contents %q, got %q", want, got)
+	}
+}
+
+func TestTOMLFrontmatter(t *testing.T) {
+	p := writeTestPost(t, `+++
+title = "TOML frontmatter"
+date = 2024-02-11T09:30:00Z
+tags = ["go", "toml"]
+weight = 3
++++
+The post.
+`)
+	post, err := NewPostFromPath(p)
+	if err != nil {
+		t.Fatalf("Unexpected error parsing post: %v", err)
+	}
+	if want, got := "TOML frontmatter", post.Title; want != got {
+		t.Errorf("Expected title %q, got %q", want, got)
+	}
+	if want, got := "2024-02-11T09:30:00Z", post.Date; want != got {
+		t.Errorf("Expected date %q, got %q", want, got)
+	}
+	if want, got := []string{"go", "toml"}, post.Tags; !reflect.DeepEqual(want, got) {
+		t.Errorf("Expected tags %q, got %q", want, got)
+	}
+	if want, got := int64(3), post.Params["weight"]; want != got {
+		t.Errorf("Expected weight param %v, got %v", want, got)
+	}
+}
+
+func TestFrontmatterClosedAtEOF(t *testing.T) {
+	p := writeTestPost(t, "---\ntitle: Only frontmatter\n---")
+	post, err := NewPostFromPath(p)
+	if err != nil {
+		t.Fatalf("Unexpected error parsing post: %v", err)
+	}
+	if want, got := "Only frontmatter", post.Title; want != got {
+		t.Errorf("Expected title %q, got %q", want, got)
+	}
+}
+
+func TestFrontmatterErrors(t *testing.T) {
+	results := []struct {
+		contents, err string
+	}{
+		{"---\ntitle: ok\nurl: a: b\n---\n", ":3: mapping values are not allowed"},
+		{"---\ntitle: ok\ntags:\n  nested: map\n---\n", `:3: "tags" must be a list of strings`},
+		{"---\n- a list\n---\n", ":2: frontmatter must be a map"},
+		{"---\ntitle: never closed\n", ":1: frontmatter is not closed"},
+		{"+++\ntitle = \"ok\"\ntitle = 3\n+++\n", ":3: "},
+		{"+++\ntitle = \"ok\"\ntags = { nested = \"map\" }\n+++\n", `:3: "tags" must be a list of strings`},
+	}
+	for _, r := range results {
+		p := writeTestPost(t, r.contents)
+		_, err := NewPostFromPath(p)
+		if err == nil {
+			t.Errorf("Expected error parsing %q", r.contents)
+		} else if !strings.HasPrefix(err.Error(), p) || !strings.Contains(err.Error(), r.err) {
+			t.Errorf("Expected error for %q to contain %q, got %q", r.contents, p+r.err, err)
+		}
+	}
+}
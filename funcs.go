@@ -0,0 +1,222 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// The number of words per minute used to estimate the reading time of a post.
+const wordsPerMinute = 200
+
+// templateFuncs returns the functions that are available in every template of
+// |blog|.
+func templateFuncs(blog *Blog) template.FuncMap {
+	return template.FuncMap{
+		// Dates.
+		"now":        time.Now,
+		"dateFormat": dateFormat,
+
+		// URLs.
+		"absURL": func(p string) string {
+			return blog.AbsoluteURL(strings.TrimPrefix(p, "/"))
+		},
+		"relURL": relURL,
+
+		// Text.
+		"truncate": truncate,
+		"markdownify": func(v interface{}) (template.HTML, error) {
+			content, err := renderMarkdown(blog, []byte(fmt.Sprint(v)))
+			return template.HTML(content), err
+		},
+		"slugify":     tagSlug,
+		"wordCount":   wordCount,
+		"readingTime": readingTime,
+
+		// Lists of posts.
+		"where":   where,
+		"first":   first,
+		"groupBy": groupBy,
+	}
+}
+
+// dateFormat formats |date|, which is a time.Time, a *time.Time, or a string
+// in one of the formats of the Date metadata, with the time.Format |layout|.
+// Missing dates are formatted as the empty string.
+func dateFormat(layout string, date interface{}) (string, error) {
+	var t time.Time
+	switch d := date.(type) {
+	case time.Time:
+		t = d
+	case *time.Time:
+		if d == nil {
+			return "", nil
+		}
+		t = *d
+	case string:
+		if d == "" {
+			return "", nil
+		}
+		t = parseDate(d)
+	default:
+		return "", fmt.Errorf("dateFormat: cannot format %T as a date", date)
+	}
+	return t.Format(layout), nil
+}
+
+// pageLinker is implemented by PageParams and by the params that embed it.
+type pageLinker interface {
+	rootLink(url string) string
+}
+
+// relURL returns a relative link from the |page| being rendered to the path
+// |p|, which is relative to the root of the blog.
+func relURL(page pageLinker, p string) string {
+	return page.rootLink(strings.TrimPrefix(p, "/"))
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// plainText returns the text of |v|, with the tags removed if it is HTML.
+func plainText(v interface{}) string {
+	if h, ok := v.(template.HTML); ok {
+		return html.UnescapeString(htmlTag.ReplaceAllString(string(h), ""))
+	}
+	return fmt.Sprint(v)
+}
+
+// truncate shortens the text of |v| to at most |length| characters, ending in
+// an ellipsis if it was shortened. HTML is converted to plain text first.
+func truncate(length int, v interface{}) string {
+	s := strings.TrimSpace(plainText(v))
+	if utf8.RuneCountInString(s) <= length {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:length])) + "…"
+}
+
+// wordCount returns the number of words in |v|, which is text, HTML, or a
+// *Post, for which the words of its Markdown are counted.
+func wordCount(v interface{}) (int, error) {
+	if post, ok := v.(*Post); ok {
+		contents, err := post.GetContents()
+		if err != nil {
+			return 0, err
+		}
+		return len(strings.Fields(string(contents))), nil
+	}
+	return len(strings.Fields(plainText(v))), nil
+}
+
+// readingTime returns the estimated number of minutes it takes to read |v|,
+// which is anything that wordCount accepts. This is at least one minute.
+func readingTime(v interface{}) (int, error) {
+	words, err := wordCount(v)
+	if err != nil {
+		return 0, err
+	}
+	minutes := (words + wordsPerMinute - 1) / wordsPerMinute
+	if minutes < 1 {
+		minutes = 1
+	}
+	return minutes, nil
+}
+
+// postValue returns the value of the field |key| of |post|, ignoring case, or
+// else the value of the metadata |key|.
+func postValue(post *Post, key string) interface{} {
+	v := reflect.ValueOf(post).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.PkgPath == "" && strings.EqualFold(field.Name, key) {
+			return v.Field(i).Interface()
+		}
+	}
+	return post.Meta(key)
+}
+
+// postValues returns the values of |key| for |post|. If the value is a list,
+// like the Tags, each item is a value. If the post has no metadata for the key,
+// it has no values.
+func postValues(post *Post, key string) []interface{} {
+	v := postValue(post, key)
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return []interface{}{v}
+	}
+	values := make([]interface{}, rv.Len())
+	for i := range values {
+		values[i] = rv.Index(i).Interface()
+	}
+	return values
+}
+
+// where returns the |posts| for which the field or metadata |key| equals
+// |value|. If the key is a list, like the Tags, the posts for which it
+// contains |value| are returned.
+func where(posts PostList, key string, value interface{}) PostList {
+	want := fmt.Sprint(value)
+	var matches PostList
+	for _, post := range posts {
+		for _, v := range postValues(post, key) {
+			if fmt.Sprint(v) == want {
+				matches = append(matches, post)
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// first returns the first |n| of the |posts|.
+func first(n int, posts PostList) PostList {
+	if n < 0 {
+		n = 0
+	}
+	if n < len(posts) {
+		return posts[:n]
+	}
+	return posts
+}
+
+// PostGroup is a list of posts that have the same value for a key.
+type PostGroup struct {
+	Key   string
+	Posts PostList
+}
+
+// groupBy groups the |posts| by the value of the field or metadata |key|. The
+// groups are ordered by the first post in each, and the posts in each group
+// keep their order. If the key is a list, like the Tags, a post is in the
+// group for each item.
+func groupBy(key string, posts PostList) []PostGroup {
+	var groups []PostGroup
+	index := make(map[string]int)
+	for _, post := range posts {
+		for _, v := range postValues(post, key) {
+			k := fmt.Sprint(v)
+			i, ok := index[k]
+			if !ok {
+				i = len(groups)
+				index[k] = i
+				groups = append(groups, PostGroup{Key: k})
+			}
+			groups[i].Posts = append(groups[i].Posts, post)
+		}
+	}
+	return groups
+}
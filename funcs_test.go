@@ -0,0 +1,140 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"html/template"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDateFormat(t *testing.T) {
+	date := time.Date(2012, time.March, 4, 0, 0, 0, 0, time.UTC)
+	results := []struct {
+		date     interface{}
+		expected string
+	}{
+		{date, "4 March 2012"},
+		{&date, "4 March 2012"},
+		{"2012-03-04", "4 March 2012"},
+		{"", ""},
+		{(*time.Time)(nil), ""},
+	}
+	for _, r := range results {
+		actual, err := dateFormat("2 January 2006", r.date)
+		if err != nil {
+			t.Errorf("Unexpected error formatting %v: %v", r.date, err)
+		} else if actual != r.expected {
+			t.Errorf("Formatting %v should be %q, got %q", r.date, r.expected, actual)
+		}
+	}
+	if _, err := dateFormat("2006", 42); err == nil {
+		t.Errorf("Expected an error formatting a number as a date")
+	}
+}
+
+func TestURLFuncs(t *testing.T) {
+	blog := &Blog{config: configFile{URL: "https://example.com/blog"}}
+	absURL := templateFuncs(blog)["absURL"].(func(string) string)
+	if want, got := "https://example.com/blog/tags/go/", absURL("/tags/go/"); want != got {
+		t.Errorf("absURL should be %q, got %q", want, got)
+	}
+
+	page := PostPageParams{PageParams: PageParams{RootPath: "../../"}}
+	if want, got := "../../feed.xml", relURL(page, "/feed.xml"); want != got {
+		t.Errorf("relURL should be %q, got %q", want, got)
+	}
+	if want, got := "./", relURL(PageParams{}, ""); want != got {
+		t.Errorf("relURL to the root should be %q, got %q", want, got)
+	}
+}
+
+func TestTextFuncs(t *testing.T) {
+	if want, got := "Hello wor…", truncate(9, "Hello world"); want != got {
+		t.Errorf("truncate should be %q, got %q", want, got)
+	}
+	if want, got := "Short", truncate(9, "Short"); want != got {
+		t.Errorf("truncate should be %q, got %q", want, got)
+	}
+	if want, got := "Fish & chi…", truncate(10, template.HTML("<p>Fish &amp; <em>chips</em></p>")); want != got {
+		t.Errorf("truncate of HTML should be %q, got %q", want, got)
+	}
+
+	words := strings.Repeat("word ", 450)
+	if n, _ := wordCount(template.HTML("<p>" + words + "</p>")); n != 450 {
+		t.Errorf("wordCount should be 450, got %d", n)
+	}
+	if n, _ := readingTime(words); n != 3 {
+		t.Errorf("readingTime should be 3, got %d", n)
+	}
+	if n, _ := readingTime(""); n != 1 {
+		t.Errorf("readingTime should be at least 1, got %d", n)
+	}
+}
+
+func TestPostListFuncs(t *testing.T) {
+	posts := PostList{
+		&Post{Title: "A", Tags: []string{"go", "web"}, Params: map[string]interface{}{"series": "intro"}},
+		&Post{Title: "B", Tags: []string{"web"}, Draft: true},
+		&Post{Title: "C", Tags: []string{"go"}, Params: map[string]interface{}{"series": "intro"}},
+	}
+	titles := func(posts PostList) []string {
+		var titles []string
+		for _, post := range posts {
+			titles = append(titles, post.Title)
+		}
+		return titles
+	}
+
+	results := []struct {
+		posts    PostList
+		expected []string
+	}{
+		{where(posts, "tags", "go"), []string{"A", "C"}},
+		{where(posts, "Draft", true), []string{"B"}},
+		{where(posts, "series", "intro"), []string{"A", "C"}},
+		{where(posts, "title", "D"), nil},
+		{first(2, posts), []string{"A", "B"}},
+		{first(5, posts), []string{"A", "B", "C"}},
+	}
+	for i, r := range results {
+		if actual := titles(r.posts); !reflect.DeepEqual(actual, r.expected) {
+			t.Errorf("Result %d should be %v, got %v", i, r.expected, actual)
+		}
+	}
+
+	groups := groupBy("tags", posts)
+	if len(groups) != 2 || groups[0].Key != "go" || groups[1].Key != "web" {
+		t.Fatalf("Expected groups go and web, got %v", groups)
+	}
+	if want, got := []string{"A", "C"}, titles(groups[0].Posts); !reflect.DeepEqual(want, got) {
+		t.Errorf("Group go should be %v, got %v", want, got)
+	}
+	if want, got := []string{"A", "B"}, titles(groups[1].Posts); !reflect.DeepEqual(want, got) {
+		t.Errorf("Group web should be %v, got %v", want, got)
+	}
+
+	// Posts without the metadata are not in any group.
+	if groups := groupBy("series", posts); len(groups) != 1 || len(groups[0].Posts) != 2 {
+		t.Errorf("Expected one group of two posts, got %v", groups)
+	}
+}
+
+func TestFuncsInTemplates(t *testing.T) {
+	blog := createTestBlog(t)
+	useTestTemplates(t, blog, map[string]string{
+		"page.html": `{{markdownify "*hi*"}} {{slugify "Go Web"}} {{absURL "feed.xml"}}`,
+	})
+	out, err := executeTemplate(blog, "page", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error executing template: %v", err)
+	}
+	if want, got := "<p><em>hi</em></p>\n go_web https://example.com/feed.xml", string(out); want != got {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
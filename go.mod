@@ -1,10 +1,18 @@
 module github.com/rsesek/blackblog
 
-go 1.12
+go 1.20
 
 require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/andybalholm/brotli v1.1.1
+	github.com/fsnotify/fsnotify v1.9.0
 	github.com/gorilla/feeds v1.1.1
 	github.com/russross/blackfriday/v2 v2.0.1
+	github.com/yuin/goldmark v1.7.8
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
 	github.com/shurcooL/sanitized_anchor_name v1.0.0 // indirect
-	github.com/yuin/goldmark v1.7.8 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
@@ -0,0 +1,140 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The path of the server-sent events endpoint that tells pages to reload.
+const reloadPath = "/_blackblog/reload"
+
+// reloadScript is injected into the HTML pages served by the server, so that
+// they reload when the blog changes.
+const reloadScript = `<script>new EventSource("` + reloadPath + `").onmessage = function() { location.reload(); };</script>`
+
+// reloader sends an event to each of the pages that are connected to it when
+// the blog changes.
+type reloader struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+
+	// Closed when the server shuts down, to end the event streams.
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newReloader() *reloader {
+	return &reloader{
+		clients: make(map[chan struct{}]bool),
+		done:    make(chan struct{}),
+	}
+}
+
+// close ends the event streams of every page, which would otherwise keep the
+// server from shutting down.
+func (r *reloader) close() {
+	r.closeOnce.Do(func() {
+		close(r.done)
+	})
+}
+
+// broadcast tells every connected page to reload.
+func (r *reloader) broadcast() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for c := range r.clients {
+		// Pages that have not handled the last event will reload anyway.
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (r *reloader) subscribe() chan struct{} {
+	c := make(chan struct{}, 1)
+	r.mu.Lock()
+	r.clients[c] = true
+	r.mu.Unlock()
+	return c
+}
+
+func (r *reloader) unsubscribe(c chan struct{}) {
+	r.mu.Lock()
+	delete(r.clients, c)
+	r.mu.Unlock()
+}
+
+// ServeHTTP streams a "reload" event to the page each time the blog changes,
+// until the page or the reloader is closed.
+func (r *reloader) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// The stream outlives the server's WriteTimeout.
+	http.NewResponseController(rw).SetWriteDeadline(time.Time{})
+
+	c := r.subscribe()
+	defer r.unsubscribe(c)
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-r.done:
+			return
+		case <-c:
+			fmt.Fprint(rw, "data: reload\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// injectErrorOverlay adds a box that shows |errs| over an HTML |page|.
+func injectErrorOverlay(page []byte, errs ...error) []byte {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = html.EscapeString(err.Error())
+	}
+	overlay := fmt.Sprintf(`<div id="blackblog-error" style="position: fixed; top: 0; left: 0; right: 0; z-index: 1000; margin: 0; padding: 1em; background: #fdd; color: #900; border-bottom: 2px solid #900; font-family: monospace; white-space: pre-wrap;">%s</div>`,
+		strings.Join(msgs, "\n"))
+	return injectBeforeBodyEnd(page, overlay)
+}
+
+// injectReloadScript adds the reloadScript to the end of the body of an HTML
+// |page|.
+func injectReloadScript(page []byte) []byte {
+	return injectBeforeBodyEnd(page, reloadScript)
+}
+
+// injectBeforeBodyEnd inserts |snippet| before the end of the body of |page|, or
+// at the end of the page if it has no closing body tag. This returns a new
+// slice, since |page| may be cached.
+func injectBeforeBodyEnd(page []byte, snippet string) []byte {
+	i := bytes.LastIndex(page, []byte("</body>"))
+	if i < 0 {
+		i = len(page)
+	}
+	out := make([]byte, 0, len(page)+len(snippet))
+	out = append(out, page[:i]...)
+	out = append(out, snippet...)
+	return append(out, page[i:]...)
+}
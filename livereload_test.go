@@ -0,0 +1,65 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReloader(t *testing.T) {
+	r := newReloader()
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if want, got := "text/event-stream", resp.Header.Get("Content-Type"); want != got {
+		t.Errorf("Expected Content-Type %q, got %q", want, got)
+	}
+
+	// Wait for the page to be connected before reloading it.
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		r.mu.Lock()
+		connected := len(r.clients)
+		r.mu.Unlock()
+		if connected == 1 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("Page did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	r.broadcast()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "data: reload\n"; line != want {
+		t.Errorf("Expected event %q, got %q", want, line)
+	}
+}
+
+func TestInjectReloadScript(t *testing.T) {
+	results := []struct {
+		page, expected string
+	}{
+		{"<html><body><p>Hi</p></body></html>", "<html><body><p>Hi</p>" + reloadScript + "</body></html>"},
+		{"<p>No body</p>", "<p>No body</p>" + reloadScript},
+	}
+	for _, r := range results {
+		if actual := string(injectReloadScript([]byte(r.page))); actual != r.expected {
+			t.Errorf("Expected %q, got %q", r.expected, actual)
+		}
+	}
+}
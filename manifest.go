@@ -0,0 +1,169 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+)
+
+// The name of the manifest file in the OutputDir.
+const manifestFileName = ".blackblog-manifest.json"
+
+// buildManifest records a key for each file written to the OutputDir, which
+// identifies the inputs that produced it. If the key for a file has not changed
+// since the last render, the file does not need to be rendered again.
+//
+// A nil *buildManifest is valid and considers every file to be out-of-date.
+type buildManifest struct {
+	// The OutputDir, to which the paths in the manifest are relative.
+	dir string
+
+	// A key for the configuration and templates, which every file depends on.
+	buildKey string
+
+	// The files from the last render.
+	previous map[string]string
+
+	// The files from this render.
+	Files map[string]string
+
+	// If true, every file is out-of-date, but the files from the last render
+	// are still known so that the stale ones can be removed.
+	force bool
+
+	// Guards previous and Files, since files are rendered concurrently.
+	mu sync.Mutex
+}
+
+// newManifest creates an empty manifest for the OutputDir |dir|.
+func newManifest(dir, buildKey string) *buildManifest {
+	return &buildManifest{
+		dir:      dir,
+		buildKey: buildKey,
+		previous: make(map[string]string),
+		Files:    make(map[string]string),
+	}
+}
+
+// loadManifest reads the manifest from the last render into the OutputDir
+// |dir|. If there is no valid manifest, this returns an empty one.
+func loadManifest(dir, buildKey string) *buildManifest {
+	m := newManifest(dir, buildKey)
+
+	f, err := os.Open(filepath.Join(dir, manifestFileName))
+	if err != nil {
+		return m
+	}
+	defer f.Close()
+
+	var last buildManifest
+	if err := json.NewDecoder(f).Decode(&last); err == nil && last.Files != nil {
+		m.previous = last.Files
+	}
+	return m
+}
+
+// save writes the files from this render to the manifest in the OutputDir.
+func (m *buildManifest) save() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.dir, manifestFileName), data, 0644)
+}
+
+func (m *buildManifest) rel(p string) string {
+	rel, err := filepath.Rel(m.dir, p)
+	if err != nil {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
+
+// isUpToDate returns true if the file at |p| exists and was produced with the
+// same |key| in the last render, in which case it is kept in this render.
+func (m *buildManifest) isUpToDate(p, key string) bool {
+	if m == nil || m.force {
+		return false
+	}
+	rel := m.rel(p)
+	m.mu.Lock()
+	last, ok := m.previous[rel]
+	m.mu.Unlock()
+	if !ok || last != key {
+		return false
+	}
+	if _, err := os.Stat(p); err != nil {
+		return false
+	}
+	m.record(p, key)
+	return true
+}
+
+// record notes that the file at |p| was written with |key| in this render.
+func (m *buildManifest) record(p, key string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Files[m.rel(p)] = key
+}
+
+// renderKey computes the key for the file at |p| that is produced from the
+// render |r|, which covers the posts that it is rendered from.
+func (m *buildManifest) renderKey(p string, r *render) string {
+	if m == nil {
+		return ""
+	}
+
+	digest := md5.New()
+	fmt.Fprintln(digest, m.buildKey, m.rel(p))
+	writeRenderInputs(digest, r)
+	return hex.EncodeToString(digest.Sum(nil))
+}
+
+// computeBuildKey returns a key for the blog configuration file, the
+// modification times of the templates, and the version of Blackblog and its
+// built-in files, so that upgrading Blackblog renders every file again.
+func computeBuildKey(blog *Blog) (string, error) {
+	digest := md5.New()
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		fmt.Fprintln(digest, info.Main.Version)
+	}
+	builtin, err := builtinFingerprint()
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(digest, builtin)
+
+	f, err := os.Open(blog.configPath)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(digest, f)
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+
+	templates, err := templatesFingerprint(blog)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(digest, templates)
+
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
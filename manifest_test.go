@@ -0,0 +1,93 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "a.html")
+
+	m := loadManifest(dir, "build")
+	if m.isUpToDate(p, "key") {
+		t.Errorf("File should not be up-to-date in an empty manifest")
+	}
+	if err := os.WriteFile(p, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m.record(p, "key")
+	m.record(filepath.Join(dir, "removed.html"), "key")
+	if err := m.save(); err != nil {
+		t.Fatalf("Unexpected error saving manifest: %v", err)
+	}
+
+	m = loadManifest(dir, "build")
+	if !m.isUpToDate(p, "key") {
+		t.Errorf("File should be up-to-date after being recorded")
+	}
+	if m.isUpToDate(p, "other") {
+		t.Errorf("File should not be up-to-date with a different key")
+	}
+	if m.isUpToDate(filepath.Join(dir, "removed.html"), "key") {
+		t.Errorf("File that does not exist should not be up-to-date")
+	}
+	if want, got := map[string]string{"a.html": "key"}, m.Files; len(got) != 1 || got["a.html"] != want["a.html"] {
+		t.Errorf("Manifest should only keep files from this render %v, got %v", want, got)
+	}
+
+	var nilManifest *buildManifest
+	nilManifest.record(p, "key")
+	if nilManifest.isUpToDate(p, nilManifest.renderKey(p, &render{})) {
+		t.Errorf("Nothing should be up-to-date in a nil manifest")
+	}
+}
+
+func TestRenderKey(t *testing.T) {
+	m := newManifest("/out", "build")
+	post := &Post{Filename: "a.md", checksum: []byte{1}}
+	r := &render{t: renderTypePost, object: post}
+
+	key := m.renderKey("/out/a.html", r)
+	if key != m.renderKey("/out/a.html", r) {
+		t.Errorf("Render key should be stable")
+	}
+	if key == m.renderKey("/out/b.html", r) {
+		t.Errorf("Render key should depend on the path")
+	}
+	if key == newManifest("/out", "other").renderKey("/out/a.html", r) {
+		t.Errorf("Render key should depend on the build key")
+	}
+
+	post.checksum = []byte{2}
+	if key == m.renderKey("/out/a.html", r) {
+		t.Errorf("Render key should depend on the post checksum")
+	}
+
+	index := &render{t: renderTypeIndex, object: &indexPage{Number: 1, NumPages: 1, Posts: PostList{post}}}
+	key = m.renderKey("/out/index.html", index)
+	index.object.(*indexPage).NumPages = 2
+	if key == m.renderKey("/out/index.html", index) {
+		t.Errorf("Render key should depend on the number of pages")
+	}
+}
+
+func TestBuiltinFingerprint(t *testing.T) {
+	first, err := builtinFingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := builtinFingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == "" || first != second {
+		t.Errorf("Expected a stable fingerprint of the built-in files, got %q and %q", first, second)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The path at which the server exposes its metrics, if the Metrics option is
+// set.
+const metricsPath = "/debug/metrics"
+
+// The upper bounds of the buckets of the render latency histogram, in seconds.
+var renderLatencyBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// serverMetrics counts the requests that the server handles by the type of
+// page, and measures the time it takes to render each type of page. The
+// metrics are written in the Prometheus text format.
+type serverMetrics struct {
+	mu sync.Mutex
+
+	// The number of requests, by the type of page.
+	requests map[string]uint64
+
+	// The render latency, by the type of page.
+	renders map[string]*latencyHistogram
+}
+
+// latencyHistogram counts durations in the renderLatencyBuckets.
+type latencyHistogram struct {
+	// The number of durations in each bucket, and not in the buckets before it.
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		requests: make(map[string]uint64),
+		renders:  make(map[string]*latencyHistogram),
+	}
+}
+
+// pageLabel returns the label for the pages of render type |t|.
+func pageLabel(t renderType) string {
+	return strings.ToLower(t.String())
+}
+
+// countRequest counts a request for a |page|, which is the label of a render
+// type or another kind of page.
+func (m *serverMetrics) countRequest(page string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[page]++
+}
+
+// observeRender records that a |page| took |d| to render.
+func (m *serverMetrics) observeRender(page string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.renders[page]
+	if !ok {
+		h = &latencyHistogram{buckets: make([]uint64, len(renderLatencyBuckets))}
+		m.renders[page] = h
+	}
+	seconds := d.Seconds()
+	h.count++
+	h.sum += seconds
+	for i, le := range renderLatencyBuckets {
+		if seconds <= le {
+			h.buckets[i]++
+			break
+		}
+	}
+}
+
+// write writes the metrics to |w| in the Prometheus text format.
+func (m *serverMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP blackblog_requests_total The number of requests served, by the type of page.")
+	fmt.Fprintln(w, "# TYPE blackblog_requests_total counter")
+	pages := make([]string, 0, len(m.requests))
+	for page := range m.requests {
+		pages = append(pages, page)
+	}
+	sort.Strings(pages)
+	for _, page := range pages {
+		fmt.Fprintf(w, "blackblog_requests_total{page=%q} %d\n", page, m.requests[page])
+	}
+
+	fmt.Fprintln(w, "# HELP blackblog_render_duration_seconds The time taken to render pages, by the type of page.")
+	fmt.Fprintln(w, "# TYPE blackblog_render_duration_seconds histogram")
+	pages = make([]string, 0, len(m.renders))
+	for page := range m.renders {
+		pages = append(pages, page)
+	}
+	sort.Strings(pages)
+	for _, page := range pages {
+		h := m.renders[page]
+		var cumulative uint64
+		for i, le := range renderLatencyBuckets {
+			cumulative += h.buckets[i]
+			fmt.Fprintf(w, "blackblog_render_duration_seconds_bucket{page=%q,le=%q} %d\n",
+				page, strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(w, "blackblog_render_duration_seconds_bucket{page=%q,le=\"+Inf\"} %d\n", page, h.count)
+		fmt.Fprintf(w, "blackblog_render_duration_seconds_sum{page=%q} %s\n", page, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "blackblog_render_duration_seconds_count{page=%q} %d\n", page, h.count)
+	}
+}
+
+func (m *serverMetrics) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(rw)
+}
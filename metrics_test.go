@@ -0,0 +1,81 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerMetrics(t *testing.T) {
+	m := newServerMetrics()
+	m.countRequest("post")
+	m.countRequest("post")
+	m.countRequest("static")
+	m.observeRender("post", 2*time.Millisecond)
+	m.observeRender("post", 2*time.Second)
+
+	var out strings.Builder
+	m.write(&out)
+	for _, want := range []string{
+		"# TYPE blackblog_requests_total counter\n",
+		`blackblog_requests_total{page="post"} 2` + "\n",
+		`blackblog_requests_total{page="static"} 1` + "\n",
+		"# TYPE blackblog_render_duration_seconds histogram\n",
+		`blackblog_render_duration_seconds_bucket{page="post",le="0.001"} 0` + "\n",
+		`blackblog_render_duration_seconds_bucket{page="post",le="0.005"} 1` + "\n",
+		`blackblog_render_duration_seconds_bucket{page="post",le="1"} 1` + "\n",
+		`blackblog_render_duration_seconds_bucket{page="post",le="5"} 2` + "\n",
+		`blackblog_render_duration_seconds_bucket{page="post",le="+Inf"} 2` + "\n",
+		`blackblog_render_duration_seconds_sum{page="post"} 2.002` + "\n",
+		`blackblog_render_duration_seconds_count{page="post"} 2` + "\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in metrics:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	blog := createTestBlog(t)
+	server, err := newBlogServer(blog)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v", err)
+	}
+	handler := server.newHTTPServer("").Handler
+
+	serve := func(url string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, httptest.NewRequest("GET", url, nil))
+		return rw
+	}
+
+	if rw := serve(metricsPath); rw.Code != http.StatusNotFound {
+		t.Errorf("Metrics should not be served unless enabled, got status %d", rw.Code)
+	}
+
+	blog.config.Metrics = true
+	serve("/post.html")
+	serve("/post.html")
+	serve("/missing.html")
+	rw := serve(metricsPath)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("Expected status %d for metrics, got %d", http.StatusOK, rw.Code)
+	}
+	out := rw.Body.String()
+	for _, want := range []string{
+		`blackblog_requests_total{page="post"} 2`,
+		`blackblog_requests_total{page="not_found"} 1`,
+		`blackblog_render_duration_seconds_count{page="post"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in metrics:\n%s", want, out)
+		}
+	}
+}
@@ -23,11 +23,13 @@ import (
 	"crypto/md5"
 	"errors"
 	"fmt"
+	"html/template"
 	"io"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -48,22 +50,50 @@ type Post struct {
 	Date       string
 	dateParsed time.Time
 
+	// The tags used to categorize the post, from the metadata.
+	Tags []string
+
+	// Whether the post is a work-in-progress that should not be published, from
+	// the metadata.
+	Draft bool
+
+	// Metadata values that are not interpreted by Blackblog, keyed by the
+	// lowercased name.
+	Params map[string]interface{}
+
+	// The HTML summary of the post, which is rendered from the Markdown before
+	// the `<!--more-->` marker, the Summary metadata, or the first paragraph.
+	Summary template.HTML
+	summary []byte
+
 	// The MD5 checksum of the file's contents.
 	checksum []byte
+
+	// The modification time of the file when it was parsed.
+	modTime time.Time
 }
 
 // GetPostsInDirectory recursively examines the directory at the path and finds
-// any Markdown (.md) files and returns the corresponding Post objects.
-func GetPostsInDirectory(dirPath string) (posts PostList, err error) {
+// any Markdown (.md) files and returns the corresponding Post objects. Posts
+// that cannot be read are skipped.
+func GetPostsInDirectory(dirPath string) (PostList, error) {
+	posts, _, err := readPostsInDirectory(dirPath)
+	return posts, err
+}
+
+// readPostsInDirectory reads the posts in a directory like GetPostsInDirectory,
+// and also returns the errors for the posts that were skipped. The returned
+// error is only for errors reading the directory itself.
+func readPostsInDirectory(dirPath string) (posts PostList, postErrs []*postError, err error) {
 	err = filepath.Walk(dirPath, func(file string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 		if !info.IsDir() && strings.HasSuffix(file, ".md") {
-			if post, err := NewPostFromPath(file); post != nil && err == nil {
+			if post, err := NewPostFromPath(file); err == nil {
 				posts = append(posts, post)
 			} else {
-				return err
+				postErrs = append(postErrs, &postError{Filename: file, Err: err})
 			}
 		}
 		return nil
@@ -71,11 +101,27 @@ func GetPostsInDirectory(dirPath string) (posts PostList, err error) {
 	return
 }
 
+// postError is an error reading or rendering the post in a file.
+type postError struct {
+	Filename string
+	Err      error
+}
+
+func (e *postError) Error() string {
+	msg := e.Err.Error()
+	if strings.HasPrefix(msg, e.Filename) {
+		return msg
+	}
+	return fmt.Sprintf("%s: %s", e.Filename, msg)
+}
+
 // NewPostFromPath creates a new Post object from the file at the given path
 // with the metadata updated.
 func NewPostFromPath(path string) (*Post, error) {
 	p := &Post{Filename: path}
-	p.UpdateMetadata()
+	if err := p.UpdateMetadata(); err != nil {
+		return nil, err
+	}
 	if len(p.checksum) < 1 {
 		return nil, errors.New("Could not checksum blog post")
 	}
@@ -111,14 +157,18 @@ func computeChecksum(file *os.File) []byte {
 	return digest.Sum(nil)
 }
 
-// GetContents returns the Markdown content of a post, excluding metadata.
+// GetContents returns the Markdown content of a post, excluding metadata. This
+// does not update the in-memory metadata, which may be in use by other
+// goroutines.
 func (p *Post) GetContents() ([]byte, error) {
-	return p.parse(parseContents)
+	reader := &Post{Filename: p.Filename}
+	return reader.parse(parseContents)
 }
 
 // UpdateMetadata re-reads the file on disk and updates the in-memory metadata.
-func (p *Post) UpdateMetadata() {
-	p.parse(parseLazily)
+func (p *Post) UpdateMetadata() error {
+	_, err := p.parse(parseLazily)
+	return err
 }
 
 type parseOptions uint
@@ -133,8 +183,6 @@ const (
 	parseContents
 )
 
-const demarcFrontmatter = "---\n"
-
 // parse parses the Post according to `opts`. Returns the post contents if
 // `opts` is `parseContents`, otherwise only returns an error if one occurs.
 func (p *Post) parse(opts parseOptions) ([]byte, error) {
@@ -149,14 +197,23 @@ func (p *Post) parse(opts parseOptions) ([]byte, error) {
 	}
 
 	p.checksum = computeChecksum(file)
+	if info, err := file.Stat(); err == nil {
+		p.modTime = info.ModTime()
+	}
 	file.Seek(0, 0)
 
-	inMetadata := false
-	isFirstLine := true
+	// Clear the metadata from a previous parse, since keys may have been
+	// removed from the file.
+	p.Title, p.URLFragment, p.Date, p.dateParsed = "", "", "", time.Time{}
+	p.Tags, p.Draft, p.Params, p.summary = nil, false, nil, nil
+
+	// The demarcation line that closes the frontmatter, if in it.
+	var demarc string
+	var frontmatter []byte
 
 	var contents []byte
 	reader := bufio.NewReader(file)
-	for {
+	for lineNum := 1; ; lineNum++ {
 		line, err := reader.ReadString('\n')
 
 		// If an error occurred, return the error except for EOF.
@@ -164,21 +221,28 @@ func (p *Post) parse(opts parseOptions) ([]byte, error) {
 			return nil, err
 		}
 
-		if isFirstLine {
-			isFirstLine = false
-
-			if line == demarcFrontmatter {
-				inMetadata = true
+		if lineNum == 1 {
+			if _, ok := frontmatterFormats[line]; ok {
+				demarc = line
 				continue
 			}
 		}
 
-		// Handle frontmatter metadata.
-		if inMetadata {
-			if line == demarcFrontmatter {
-				inMetadata = false
-			} else if err := p.parseMetadataPair(line); err != nil {
-				return nil, err
+		// Handle frontmatter metadata. The closing demarcation line may end the
+		// file without a newline.
+		if demarc != "" {
+			if line == demarc || (err == io.EOF && line == strings.TrimSuffix(demarc, "\n")) {
+				if err := p.parseFrontmatter(demarc, frontmatter); err != nil {
+					return nil, err
+				}
+				demarc = ""
+				if err == io.EOF {
+					break
+				}
+			} else if err == io.EOF {
+				return nil, fmt.Errorf("%s:1: frontmatter is not closed by %q", p.Filename, strings.TrimSpace(demarc))
+			} else {
+				frontmatter = append(frontmatter, line...)
 			}
 			continue
 		}
@@ -186,7 +250,7 @@ func (p *Post) parse(opts parseOptions) ([]byte, error) {
 		// Handle prefix-line metadata.
 		if len(line) >= 2 && line[:2] == "~~" {
 			if err := p.parseMetadataLine(line); err != nil {
-				return nil, err
+				return nil, fmt.Errorf("%s:%d: %v", p.Filename, lineNum, err)
 			}
 			continue
 		}
@@ -203,9 +267,35 @@ func (p *Post) parse(opts parseOptions) ([]byte, error) {
 			break
 		}
 	}
+
+	if p.summary == nil {
+		p.summary = extractSummary(contents)
+	}
 	return contents, nil
 }
 
+// moreMarker separates the summary of a post from the rest of its contents.
+const moreMarker = "<!--more-->"
+
+// extractSummary returns the Markdown summary of the post |contents|, which is
+// everything before the moreMarker or else the first paragraph.
+func extractSummary(contents []byte) []byte {
+	// Paragraphs are found by their blank lines, which need the same line
+	// endings.
+	contents = bytes.ReplaceAll(contents, []byte("\r\n"), []byte("\n"))
+	if i := bytes.Index(contents, []byte(moreMarker)); i >= 0 {
+		return bytes.TrimSpace(contents[:i])
+	}
+	for _, block := range bytes.Split(contents, []byte("\n\n")) {
+		block = bytes.TrimSpace(block)
+		// Skip over headings to find the first paragraph.
+		if len(block) > 0 && block[0] != '#' {
+			return block
+		}
+	}
+	return []byte{}
+}
+
 func (p *Post) parseMetadataLine(line string) error {
 	if len(line) < 2 || line[0:2] != "~~" {
 		return errors.New("metadata lines should start with \"~~\"")
@@ -219,18 +309,52 @@ func (p *Post) parseMetadataPair(line string) error {
 		return fmt.Errorf("invalid format for metadata pair: %q", line)
 	}
 
-	val := strings.TrimSpace(pieces[1])
+	p.setMetadata(strings.ToLower(strings.TrimSpace(pieces[0])), strings.TrimSpace(pieces[1]))
+	return nil
+}
 
-	switch strings.ToLower(strings.TrimSpace(pieces[0])) {
+func (p *Post) setMetadata(key, val string) {
+	switch key {
 	case "title":
 		p.Title = val
 	case "url":
 		p.URLFragment = val
 	case "date":
 		p.Date = val
+		p.dateParsed = parseDate(val)
+	case "summary":
+		p.summary = []byte(val)
+	case "draft":
+		draft, err := strconv.ParseBool(val)
+		p.Draft = (err == nil && draft) || strings.EqualFold(val, "yes")
+	case "tags":
+		// Tags are either a comma-separated list or a YAML flow sequence.
+		val = strings.TrimSuffix(strings.TrimPrefix(val, "["), "]")
+		for _, tag := range strings.Split(val, ",") {
+			tag = strings.Trim(strings.TrimSpace(tag), `"'`)
+			if tag != "" {
+				p.Tags = append(p.Tags, tag)
+			}
+		}
+	default:
+		// Posts can have metadata that is not interpreted by Blackblog, which is
+		// kept for the templates.
+		p.setParam(key, val)
 	}
-	// Posts can have metadata that is not interpreted by Blackblog.
-	return nil
+}
+
+func (p *Post) setParam(key string, val interface{}) {
+	if p.Params == nil {
+		p.Params = make(map[string]interface{})
+	}
+	p.Params[key] = val
+}
+
+// Meta returns the value of a metadata key that is not interpreted by
+// Blackblog, or nil if the post does not have it. The key is not case
+// sensitive.
+func (p *Post) Meta(key string) interface{} {
+	return p.Params[strings.ToLower(key)]
 }
 
 var urlFromBasename = regexp.MustCompile("[^A-Za-z0-9_]+")
@@ -256,35 +380,61 @@ func (p *Post) CreateURL() string {
 	url := basename + ".html"
 
 	// Next, try and get the date of the post to include subdirectories.
-	if p.dateParsed = parseDate(p.Date); !p.dateParsed.IsZero() {
-		year, month, _ := p.dateParsed.Date()
+	if date := p.date(); !date.IsZero() {
+		year, month, _ := date.Date()
 		url = path.Join(strconv.FormatInt(int64(year), 10), strconv.Itoa(int(month)), url)
 	}
 
 	return url
 }
 
+// tagSlug converts a tag name into the URL fragment used for its index page.
+func tagSlug(tag string) string {
+	return strings.Trim(strings.ToLower(urlFromBasename.ReplaceAllString(tag, "_")), "_")
+}
+
 func (p *Post) CreatePermalink(b *Blog) string {
-	component := p.CreateURL()
-	base := b.URL()
-	if !strings.HasSuffix(base, "/") {
-		base += "/"
-	}
-	return base + component
+	return b.AbsoluteURL(p.CreateURL())
 }
 
 func (p *Post) GetDate() *time.Time {
 	if p.Date == "" {
 		return nil
 	}
-	return &p.dateParsed
+	date := p.date()
+	return &date
+}
+
+// date returns the parsed Date. Posts that were not parsed from a file only
+// have the Date string, so it is parsed on demand.
+func (p *Post) date() time.Time {
+	if p.dateParsed.IsZero() {
+		return parseDate(p.Date)
+	}
+	return p.dateParsed
+}
+
+// IsScheduled returns true if the post is dated after the current time.
+func (p *Post) IsScheduled() bool {
+	return p.isScheduledAt(time.Now())
+}
+
+func (p *Post) isScheduledAt(now time.Time) bool {
+	date := p.date()
+	return !date.IsZero() && date.After(now)
+}
+
+// IsPublished returns true if the post is neither a draft nor scheduled for
+// after |now|.
+func (p *Post) IsPublished(now time.Time) bool {
+	return !p.Draft && !p.isScheduledAt(now)
 }
 
 func (p *Post) FormatDate(format string) string {
 	if p.Date == "" {
 		return ""
 	}
-	return p.dateParsed.Format(format)
+	return p.date().Format(format)
 }
 
 func parseDate(input string) time.Time {
@@ -297,6 +447,11 @@ func parseDate(input string) time.Time {
 		return t
 	}
 
+	t, err = time.Parse(time.RFC3339, input)
+	if err == nil {
+		return t
+	}
+
 	t, err = time.Parse("_2 January 2006", input)
 	if err == nil {
 		return t
@@ -315,23 +470,56 @@ func parseDate(input string) time.Time {
 	return time.Time{}
 }
 
-// sort.Interface implementation:
-
 type PostList []*Post
 
+// Published returns the posts in the list that are published as of |now|.
+func (pl PostList) Published(now time.Time) PostList {
+	published := make(PostList, 0, len(pl))
+	for _, p := range pl {
+		if p.IsPublished(now) {
+			published = append(published, p)
+		}
+	}
+	return published
+}
+
+// nextScheduled returns the earliest date after |now| of a post that is not a
+// draft, which is when it will be published, or the zero time if there is none.
+func (pl PostList) nextScheduled(now time.Time) time.Time {
+	var next time.Time
+	for _, p := range pl {
+		if p.Draft || !p.isScheduledAt(now) {
+			continue
+		}
+		if date := p.date(); next.IsZero() || date.Before(next) {
+			next = date
+		}
+	}
+	return next
+}
+
+// sorted returns a sorted copy of the list. Lists of posts are shared by the
+// nodes of a renderTree, which may be rendered concurrently, so they should not
+// be sorted in place.
+func (pl PostList) sorted() PostList {
+	sorted := make(PostList, len(pl))
+	copy(sorted, pl)
+	sort.Sort(sorted)
+	return sorted
+}
+
+// sort.Interface implementation:
+
 func (pl PostList) Len() int {
 	return len(pl)
 }
 
 func (pl PostList) Less(i, j int) bool {
-	// Call CreateURL first to ensure the date is parsed.
-	ui, uj := pl[i].CreateURL(), pl[j].CreateURL()
-	di, dj := pl[i].dateParsed, pl[j].dateParsed
-
+	di, dj := pl[i].date(), pl[j].date()
 	if !di.IsZero() && !dj.IsZero() {
 		return di.Before(dj)
 	}
-	return ui < uj
+	return pl[i].CreateURL() < pl[j].CreateURL()
 }
 
 func (pl PostList) Swap(i, j int) {
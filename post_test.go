@@ -18,6 +18,8 @@
 package main
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -57,6 +59,33 @@ func TestParseMetadataLine(t *testing.T) {
 		}
 	}
 
+	var p Post
+	p.parseMetadataLine("~~ Author: Inigo Montoya")
+	if want, got := "Inigo Montoya", p.Meta("author"); want != got {
+		t.Errorf("Expected author metadata %q, got %v", want, got)
+	}
+	if want, got := "Inigo Montoya", p.Meta("AUTHOR"); want != got {
+		t.Errorf("Expected case-insensitive author metadata %q, got %v", want, got)
+	}
+	if got := p.Meta("title"); got != nil {
+		t.Errorf("Expected no value for missing metadata, got %v", got)
+	}
+
+	tagInput := map[string][]string{
+		"~~ Tags: go":                       {"go"},
+		"~~ Tags: go, Web Development,  ":   {"go", "Web Development"},
+		"~~ tags: [go, \"yaml\", 'quoted']": {"go", "yaml", "quoted"},
+	}
+	for input, tags := range tagInput {
+		var p Post
+		if err := p.parseMetadataLine(input); err != nil {
+			t.Errorf("Unexpected parse error for %q", input)
+		}
+		if !reflect.DeepEqual(p.Tags, tags) {
+			t.Errorf("Parse error for input %q, expected tags %q, got %q", input, tags, p.Tags)
+		}
+	}
+
 	badInput := []string{
 		"-- Title: bad",
 		"~~~ Title",
@@ -107,6 +136,90 @@ func TestIsOutOfDate(t *testing.T) {
 	}
 }
 
+func TestDraftMetadata(t *testing.T) {
+	results := map[string]bool{
+		"~~ Draft: true":  true,
+		"~~ draft: yes":   true,
+		"~~ Draft: 1":     true,
+		"~~ Draft: false": false,
+		"~~ Draft: maybe": false,
+	}
+	for input, draft := range results {
+		var p Post
+		if err := p.parseMetadataLine(input); err != nil {
+			t.Errorf("Unexpected parse error for %q", input)
+		}
+		if p.Draft != draft {
+			t.Errorf("Parse error for input %q, expected Draft=%v", input, draft)
+		}
+	}
+}
+
+func TestPublished(t *testing.T) {
+	now := time.Date(2020, 5, 22, 12, 0, 0, 0, time.UTC)
+	posts := PostList{
+		&Post{Title: "Undated"},
+		&Post{Title: "Past", Date: "21 May 2020"},
+		&Post{Title: "Today", Date: "22 May 2020"},
+		&Post{Title: "Future", Date: "23 May 2020"},
+		&Post{Title: "Draft", Date: "21 May 2020", Draft: true},
+	}
+	published := posts.Published(now)
+	expected := []string{"Undated", "Past", "Today"}
+	if len(published) != len(expected) {
+		t.Fatalf("Expected %d published posts, got %d", len(expected), len(published))
+	}
+	for i, title := range expected {
+		if published[i].Title != title {
+			t.Errorf("Published post %d should be %q, got %q", i, title, published[i].Title)
+		}
+	}
+}
+
+func TestExtractSummary(t *testing.T) {
+	results := map[string]string{
+		"First paragraph.\n\nSecond paragraph.\n":       "First paragraph.",
+		"\n# Heading\n\nFirst\nparagraph.\n\nSecond.\n": "First\nparagraph.",
+		"One.\n\nTwo.\n<!--more-->\nThree.\n":           "One.\n\nTwo.",
+		"# Only a heading\n":                            "",
+		"First\r\nparagraph.\r\n\r\nSecond.\r\n":        "First\nparagraph.",
+	}
+	for input, expected := range results {
+		if actual := string(extractSummary([]byte(input))); actual != expected {
+			t.Errorf("Summary of %q should be %q, got %q", input, expected, actual)
+		}
+	}
+
+	var p Post
+	p.parseMetadataLine("~~ Summary: From the *metadata*.")
+	if want, got := "From the *metadata*.", string(p.summary); want != got {
+		t.Errorf("Expected summary %q, got %q", want, got)
+	}
+}
+
+func TestSummaryURLs(t *testing.T) {
+	blog := createTestBlog(t)
+	post := &Post{
+		Title:   "Links",
+		Date:    "22 May 2020",
+		summary: []byte(`[Near](other.html?a=1&b=2) ![Up](../img.png) [Root](/about.html) [Abs](https://example.org/x) [Note](#fn1)`),
+	}
+	if err := renderSummary(blog, post); err != nil {
+		t.Fatalf("Unexpected error rendering summary: %v", err)
+	}
+	for _, want := range []string{
+		`href="https://example.com/2020/5/other.html?a=1&amp;b=2"`,
+		`src="https://example.com/2020/img.png"`,
+		`href="/about.html"`,
+		`href="https://example.org/x"`,
+		`href="https://example.com/2020/5/links.html#fn1"`,
+	} {
+		if !strings.Contains(string(post.Summary), want) {
+			t.Errorf("Expected %s in summary, got %q", want, post.Summary)
+		}
+	}
+}
+
 type createURL struct {
 	url  string
 	post Post
@@ -167,13 +280,15 @@ func TestParseDate(t *testing.T) {
 		{"August 2 2011", time.Date(2011, 8, 2, 0, 0, 0, 0, loc)},
 		{"March 2, 2012", time.Date(2012, 3, 2, 0, 0, 0, 0, loc)},
 		{"2024-02-11", time.Date(2024, 2, 11, 0, 0, 0, 0, loc)},
+		{"2024-02-11T09:30:00Z", time.Date(2024, 2, 11, 9, 30, 0, 0, loc)},
+		{"2024-02-11T09:30:00+02:00", time.Date(2024, 2, 11, 7, 30, 0, 0, loc)},
 	}
 
 	for _, r := range results {
 		actual := parseDate(r.in)
 		if actual.IsZero() {
 			t.Errorf("Failed to parse input '%s'", r.in)
-		} else if actual.Year() != r.out.Year() || actual.Month() != r.out.Month() || actual.Day() != r.out.Day() {
+		} else if !actual.Equal(r.out) {
 			t.Errorf("Date parse fail. Input '%s', expected '%v', got '%v'", r.in, r.out, actual)
 		}
 	}
@@ -190,10 +305,16 @@ func TestFrontmatter(t *testing.T) {
 	if want, got := "2024-02-11", post.Date; want != got {
 		t.Errorf("Expected date %q, got %q", want, got)
 	}
+	if want, got := []string{"yaml", "Frontmatter"}, post.Tags; !reflect.DeepEqual(want, got) {
+		t.Errorf("Expected tags %q, got %q", want, got)
+	}
 	contents, err := post.GetContents()
 	if err != nil {
 		t.Errorf("Failed to get post contents: %v", err)
 	}
+	if want, got := 2, len(post.Tags); want != got {
+		t.Errorf("Expected %d tags after reparsing, got %d", want, got)
+	}
 	if want, got := "This is a post.\n", string(contents); want != got {
 		t.Errorf("Wanted contents %q, got %q", want, got)
 	}
@@ -20,10 +20,14 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"html"
+	"html/template"
+	"net/url"
 	"path"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/gorilla/feeds"
@@ -39,24 +43,34 @@ func RenderPost(post *Post, page PageParams) ([]byte, error) {
 		return nil, err
 	}
 
-	tpl, err := page.getTemplate("post")
-	if err != nil {
-		return nil, err
-	}
-
 	page.Title = post.Title
 	params := PostPageParams{
 		Post:       post,
-		Content:    content,
+		Content:    template.HTML(content),
 		PageParams: page,
 	}
 
-	buf := new(bytes.Buffer)
-	if err := tpl.Execute(buf, params); err != nil {
-		return nil, err
-	}
+	return renderPage("post", params, params.PageParams)
+}
 
-	return wrapPage(buf.Bytes(), params.PageParams)
+// renderNode renders the contents of the file for a render that is not a
+// directory.
+func renderNode(blog *Blog, r *render) ([]byte, error) {
+	switch r.t {
+	case renderTypePost:
+		return RenderPost(r.object.(*Post), CreatePageParams(blog, r))
+	case renderTypeTag:
+		return CreateTagIndex(r.object.(*tagIndex), CreatePageParams(blog, r))
+	case renderTypeArchive:
+		return CreateArchive(r.object.(*archive), CreatePageParams(blog, r))
+	case renderTypeIndex:
+		return CreateIndex(r.object.(*indexPage), CreatePageParams(blog, r))
+	case renderTypeFeed:
+		return CreateXMLFeed(r.object.(*postFeed), blog)
+	case renderTypeRedirect:
+		return []byte(generateRedirect(r.object.(string))), nil
+	}
+	return nil, fmt.Errorf("unknown renderType %v", r.t)
 }
 
 func renderPostMarkdown(blog *Blog, post *Post) (string, error) {
@@ -64,10 +78,62 @@ func renderPostMarkdown(blog *Blog, post *Post) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	return renderMarkdown(blog, bytes.Replace(data, []byte(moreMarker), nil, 1))
+}
+
+// renderSummaries renders the Markdown summary of each post into its Summary.
+// It returns the posts whose summaries were rendered, and the errors for the
+// others.
+func renderSummaries(blog *Blog, posts PostList) (PostList, []*postError) {
+	var rendered PostList
+	var postErrs []*postError
+	for _, post := range posts {
+		if err := renderSummary(blog, post); err != nil {
+			postErrs = append(postErrs, &postError{Filename: post.Filename, Err: err})
+			continue
+		}
+		rendered = append(rendered, post)
+	}
+	return rendered, postErrs
+}
+
+// renderSummary renders the Markdown summary of |post| into its Summary. The
+// summary is shown on other pages and in the feeds, so its relative links are
+// made absolute against the post's permalink.
+func renderSummary(blog *Blog, post *Post) error {
+	summary, err := renderMarkdown(blog, post.summary)
+	if err != nil {
+		return fmt.Errorf("%s: summary: %v", post.Filename, err)
+	}
+	base, err := url.Parse(post.CreatePermalink(blog))
+	if err != nil {
+		return fmt.Errorf("%s: summary: %v", post.Filename, err)
+	}
+	post.Summary = template.HTML(absoluteURLs(summary, base))
+	return nil
+}
 
+var urlAttr = regexp.MustCompile(`(\s(?:href|src)=")([^"]*)"`)
+
+// absoluteURLs resolves the relative URLs in the href and src attributes of
+// the HTML |content| against |base|. URLs that are absolute or that start with
+// a slash are kept.
+func absoluteURLs(content string, base *url.URL) string {
+	return urlAttr.ReplaceAllStringFunc(content, func(attr string) string {
+		m := urlAttr.FindStringSubmatch(attr)
+		value := html.UnescapeString(m[2])
+		ref, err := url.Parse(value)
+		if err != nil || ref.IsAbs() || strings.HasPrefix(value, "/") {
+			return attr
+		}
+		return m[1] + html.EscapeString(base.ResolveReference(ref).String()) + `"`
+	})
+}
+
+func renderMarkdown(blog *Blog, data []byte) (string, error) {
 	if blog.config.ConfigVersion == configVersion {
 		var buf strings.Builder
-		err = blog.md.Convert(data, &buf)
+		err := blog.md.Convert(data, &buf)
 		return buf.String(), err
 	}
 
@@ -81,36 +147,67 @@ func renderPostMarkdown(blog *Blog, post *Post) (string, error) {
 	return string(content), nil
 }
 
-// CreateIndex takes the sorted list of posts and generates HTML output listing
-// each one.
-func CreateIndex(posts PostList, blog *Blog) ([]byte, error) {
-	page := CreatePageParams(blog, nil)
-
-	tpl, err := page.getTemplate("index")
-	if err != nil {
-		return nil, err
-	}
-
-	sort.Sort(posts)
-
+// CreateIndex generates the HTML output listing each post on a page of the
+// index.
+func CreateIndex(index *indexPage, page PageParams) ([]byte, error) {
 	page.Title = "Posts"
+	if index.Number > 1 {
+		page.Title = fmt.Sprintf("Posts - Page %d", index.Number)
+	}
 	params := IndexPageParams{
-		Posts:      posts,
+		Posts:      index.Posts,
 		PageParams: page,
+		Page:       index.Number,
+		NumPages:   index.NumPages,
+	}
+	if index.Number > 1 {
+		params.PrevPage = page.rootLink(indexPageURL(index.Number - 1))
+	}
+	if index.Number < index.NumPages {
+		params.NextPage = page.rootLink(indexPageURL(index.Number + 1))
 	}
 
-	buf := new(bytes.Buffer)
-	err = tpl.Execute(buf, params)
-	if err != nil {
-		return nil, err
+	return renderPage("index", params, params.PageParams)
+}
+
+// CreateTagIndex generates the HTML output listing each post with the given
+// tag.
+func CreateTagIndex(tag *tagIndex, page PageParams) ([]byte, error) {
+	page.Title = tag.Name
+	params := TagPageParams{
+		IndexPageParams: IndexPageParams{
+			Posts:      tag.Posts,
+			PageParams: page,
+		},
+		Tag: tag.Name,
 	}
 
-	return wrapPage(buf.Bytes(), params.PageParams)
+	return renderPage("tag", params, params.PageParams)
 }
 
-// CreateXMLFeed takes a list of posts and generates an XML
-// document for an Atom feed.
-func CreateXMLFeed(posts PostList, blog *Blog) ([]byte, error) {
+// CreateArchive generates the HTML output listing each post published in the
+// period of the archive.
+func CreateArchive(a *archive, page PageParams) ([]byte, error) {
+	params := ArchivePageParams{
+		IndexPageParams: IndexPageParams{
+			Posts:      a.Posts,
+			PageParams: page,
+		},
+		Year:  a.Year,
+		Month: a.Month,
+	}
+	params.Title = strconv.Itoa(a.Year)
+	if a.Month != 0 {
+		params.Title = fmt.Sprintf("%s %d", a.Month, a.Year)
+	}
+
+	return renderPage("archive", params, params.PageParams)
+}
+
+// CreateXMLFeed takes a feed of posts and generates an XML document for an Atom
+// feed.
+func CreateXMLFeed(pf *postFeed, blog *Blog) ([]byte, error) {
+	posts := pf.Posts.sorted()
 	sort.Sort(sort.Reverse(posts))
 
 	numPosts := len(posts)
@@ -138,16 +235,21 @@ func CreateXMLFeed(posts PostList, blog *Blog) ([]byte, error) {
 		}
 
 		items = append(items, &feeds.Item{
-			Title:   post.Title,
-			Link:    &feeds.Link{Href: post.CreatePermalink(blog)},
-			Created: *date,
-			Content: content,
+			Title:       post.Title,
+			Link:        &feeds.Link{Href: post.CreatePermalink(blog)},
+			Created:     *date,
+			Description: string(post.Summary),
+			Content:     content,
 		})
 	}
+	title := blog.Title()
+	if pf.Subtitle != "" {
+		title = fmt.Sprintf("%s - %s", title, pf.Subtitle)
+	}
 	feed := &feeds.Feed{
-		Title:       blog.Title(),
-		Description: fmt.Sprintf("Recent posts on %s", blog.Title()),
-		Link:        &feeds.Link{Href: blog.URL()},
+		Title:       title,
+		Description: fmt.Sprintf("Recent posts on %s", title),
+		Link:        &feeds.Link{Href: blog.AbsoluteURL(pf.Dir)},
 		Created:     latestPost,
 		Updated:     generated,
 		Items:       items,
@@ -170,23 +272,31 @@ type PageParams struct {
 
 	// Relative path to the page being rendered.
 	URL string
+
+	// The post being rendered, or nil if the page is not for a single post.
+	Post *Post
 }
 
 // CreatePageParams sets up the parameters for PageParams.
 func CreatePageParams(blog *Blog, render *render) PageParams {
 	var url, rootPath string
+	var post *Post
 	if render == nil {
 		url = "index.html"
 		rootPath = ""
-	} else {
-		post := render.object.(*Post)
+	} else if p, ok := render.object.(*Post); ok {
+		post = p
 		url = post.CreateURL()
 		rootPath = depthPath(render)
+	} else {
+		url = nodeURL(render)
+		rootPath = depthPath(render)
 	}
 	return PageParams{
 		Blog:     blog,
 		RootPath: rootPath,
 		URL:      url,
+		Post:     post,
 	}
 }
 
@@ -201,10 +311,31 @@ func (p PageParams) StaticFileLink(file string) string {
 	return path.Join(p.RootPath, StaticFilesDir[1:], file)
 }
 
+// TagLink returns a link to the index page for a tag.
+func (p PageParams) TagLink(tag string) string {
+	return path.Join(p.RootPath, tagsDir, tagSlug(tag)) + "/"
+}
+
+// rootLink returns a relative link to a path from the root of the blog.
+func (p PageParams) rootLink(url string) string {
+	if link := p.RootPath + url; link != "" {
+		return link
+	}
+	return "./"
+}
+
 // IndexPageParams is used to render out the blog post list page.
 type IndexPageParams struct {
 	PageParams
 	Posts PostList
+
+	// The 1-based number of this page of the index, and the total number of
+	// pages. Lists that are not paginated only have one page.
+	Page, NumPages int
+
+	// Relative links to the previous (newer) and next (older) pages of the
+	// index, or empty if there is none.
+	PrevPage, NextPage string
 }
 
 func (p IndexPageParams) PostsDescending() PostList {
@@ -216,59 +347,80 @@ func (p IndexPageParams) PostsAscending() PostList {
 }
 
 func (p IndexPageParams) PostsDescendingLimit(limit int) PostList {
-	sort.Sort(sort.Reverse(p.Posts))
-	if limit < len(p.Posts) && limit > 0 {
-		return p.Posts[:limit]
+	posts := p.Posts.sorted()
+	sort.Sort(sort.Reverse(posts))
+	if limit < len(posts) && limit > 0 {
+		return posts[:limit]
 	}
-	return p.Posts
+	return posts
 }
 
 func (p IndexPageParams) PostsAscendingLimit(limit int) PostList {
-	sort.Sort(p.Posts)
-	if limit < len(p.Posts) && limit > 0 {
-		return p.Posts[:limit]
+	posts := p.Posts.sorted()
+	if limit < len(posts) && limit > 0 {
+		return posts[:limit]
 	}
-	return p.Posts
+	return posts
+}
+
+// TagPageParams is used to render out the list of posts with a tag.
+type TagPageParams struct {
+	IndexPageParams
+	Tag string
+}
+
+// ArchivePageParams is used to render out the list of posts published in a
+// year or a month.
+type ArchivePageParams struct {
+	IndexPageParams
+	Year int
+	// The month of the archive, or zero if this is the archive for a year.
+	Month time.Month
 }
 
 // PostPageParams is used for displaying a rendered post.
 type PostPageParams struct {
 	PageParams
 	Post    *Post
-	Content string // The HTML content rendered from (*Post).GetContents() markdown original.
+	Content template.HTML // The HTML content rendered from (*Post).GetContents() markdown original.
 }
 
-func wrapPage(content []byte, vars PageParams) ([]byte, error) {
-	buf := new(bytes.Buffer)
-
-	header, err := vars.getTemplate("header")
+// renderPage executes the page template |name| with |data|. If the templates
+// include a layout, the page is rendered within it. Otherwise, the page is
+// wrapped by the header and footer, which are executed with |vars|.
+func renderPage(name string, data interface{}, vars PageParams) ([]byte, error) {
+	t, err := vars.Blog.templates()
 	if err != nil {
 		return nil, err
 	}
+	if t.hasLayout() {
+		return t.executePage(name, data)
+	}
 
-	footer, err := vars.getTemplate("footer")
+	body, err := execute(t.set, name+".html", data)
 	if err != nil {
 		return nil, err
 	}
+	return wrapPage(body, vars)
+}
 
-	if err := header.Execute(buf, vars); err != nil {
+func wrapPage(content []byte, vars PageParams) ([]byte, error) {
+	header, err := executeTemplate(vars.Blog, "header", vars)
+	if err != nil {
 		return nil, err
 	}
 
-	buf.Write(content)
-
-	if err := footer.Execute(buf, vars); err != nil {
+	footer, err := executeTemplate(vars.Blog, "footer", vars)
+	if err != nil {
 		return nil, err
 	}
 
+	buf := bytes.NewBuffer(header)
+	buf.Write(content)
+	buf.Write(footer)
 	return buf.Bytes(), nil
 }
 
-func (p *PageParams) getTemplate(name string) (*template.Template, error) {
-	name = path.Join(p.Blog.TemplatesDir(), name+".html")
-	return template.ParseFiles(name)
-}
-
 func generateRedirect(url string) string {
 	return fmt.Sprintf(`<html><head><meta http-equiv="refresh" content="0;url=%s"></head></html>`, url)
 }
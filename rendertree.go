@@ -19,9 +19,13 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type renderType int
@@ -31,7 +35,10 @@ const (
 	renderTypePost                        // A Post object.
 	renderTypeDirectory                   // A renderTree.
 	renderTypeRedirect                    // Link back to the root.
-	renderTypeFeed                        // A PostList.
+	renderTypeFeed                        // A postFeed.
+	renderTypeTag                         // A tagIndex.
+	renderTypeIndex                       // An indexPage.
+	renderTypeArchive                     // An archive.
 )
 
 // A renderTree maps a URL fragment to a render object for the current level in
@@ -45,44 +52,261 @@ type render struct {
 	parent *render
 }
 
-func (r *render) String() string {
-	var t string
-	switch r.t {
+func (t renderType) String() string {
+	switch t {
 	case renderTypeInvalid:
-		t = "Invalid"
+		return "Invalid"
 	case renderTypePost:
-		t = "Post"
+		return "Post"
 	case renderTypeDirectory:
-		t = "Dir"
+		return "Dir"
 	case renderTypeRedirect:
-		t = "Redirect"
+		return "Redirect"
 	case renderTypeFeed:
-		t = "Feed"
+		return "Feed"
+	case renderTypeTag:
+		return "Tag"
+	case renderTypeIndex:
+		return "Index"
+	case renderTypeArchive:
+		return "Archive"
 	default:
-		t = "???"
+		return "???"
 	}
-	return fmt.Sprintf("render%s(%p){%v %p}", t, r, r.object, r.parent)
+}
+
+func (r *render) String() string {
+	return fmt.Sprintf("render%v(%p){%v %p}", r.t, r, r.object, r.parent)
 }
 
 // createRenderTree takes a slice of posts and returns the root node of the
-// renderTree.
-func createRenderTree(posts PostList) (*render, error) {
+// renderTree. The index of posts is split into pages of |postsPerPage|, or is a
+// single page if that is not positive.
+func createRenderTree(posts PostList, postsPerPage int) (*render, error) {
 	root := &render{
 		t:      renderTypeDirectory,
 		object: make(renderTree),
 	}
-	root.object.(renderTree)["feed.xml"] = &render{
+	root.object.(renderTree)[feedFile] = &render{
 		t:      renderTypeFeed,
-		object: posts,
+		object: &postFeed{Posts: posts},
+		parent: root,
 	}
 	for _, p := range posts {
 		if err := insertPost(p, root); err != nil {
 			return nil, err
 		}
 	}
+	insertArchives(posts, root)
+	if err := insertTags(posts, root); err != nil {
+		return nil, err
+	}
+	if err := insertIndexPages(posts, postsPerPage, root); err != nil {
+		return nil, err
+	}
 	return root, nil
 }
 
+// indexPage is one page of the index of all posts.
+type indexPage struct {
+	// The 1-based page number and the total number of pages.
+	Number, NumPages int
+
+	Posts PostList
+}
+
+// pagesDir is the directory under which the index pages after the first are
+// placed.
+const pagesDir = "page"
+
+// indexPageURL returns the directory of the index page |n|, relative to the
+// root.
+func indexPageURL(n int) string {
+	if n <= 1 {
+		return ""
+	}
+	return path.Join(pagesDir, strconv.Itoa(n)) + "/"
+}
+
+// insertIndexPages splits the posts, newest first, into pages of
+// |postsPerPage| and places an indexPage for each one into the renderTree
+// root. The first page is the root index.html and the rest are at
+// `page/<n>/index.html`.
+func insertIndexPages(posts PostList, postsPerPage int, root *render) error {
+	sorted := make(PostList, len(posts))
+	copy(sorted, posts)
+	sort.Sort(sort.Reverse(sorted))
+
+	if postsPerPage <= 0 || postsPerPage > len(sorted) {
+		postsPerPage = len(sorted)
+	}
+	numPages := 1
+	if postsPerPage > 0 {
+		numPages = (len(sorted) + postsPerPage - 1) / postsPerPage
+	}
+
+	for n := 1; n <= numPages; n++ {
+		start := (n - 1) * postsPerPage
+		end := start + postsPerPage
+		if end > len(sorted) {
+			end = len(sorted)
+		}
+
+		dir, err := findOrCreateDirNode(indexPageURL(n)+"index.html", root)
+		if err != nil {
+			return err
+		}
+		dir.object.(renderTree)["index.html"] = &render{
+			t: renderTypeIndex,
+			object: &indexPage{
+				Number:   n,
+				NumPages: numPages,
+				Posts:    sorted[start:end],
+			},
+			parent: dir,
+		}
+	}
+	return nil
+}
+
+// The name of the Atom feed file placed in directories.
+const feedFile = "feed.xml"
+
+// postFeed is a list of posts that is rendered as an Atom feed.
+type postFeed struct {
+	// Describes the subset of posts in the feed, e.g. the tag name. This is
+	// empty for the feed of all posts.
+	Subtitle string
+
+	// The directory of the feed, relative to the root of the blog.
+	Dir string
+
+	Posts PostList
+}
+
+// archive is the list of posts published in a year or in a month.
+type archive struct {
+	Year int
+	// The month of the archive, or zero if this is the archive for a year.
+	Month time.Month
+
+	Posts PostList
+}
+
+// insertArchives places an archive in each year and month directory of the
+// renderTree root, and a postFeed in each year directory, containing the posts
+// published in that period. This must be called after the posts are inserted.
+func insertArchives(posts PostList, root *render) {
+	rt := root.object.(renderTree)
+	for _, p := range posts {
+		date := p.GetDate()
+		if date == nil || date.IsZero() {
+			continue
+		}
+		year := strconv.Itoa(date.Year())
+		yearDir, ok := rt[year]
+		if !ok || yearDir.t != renderTypeDirectory {
+			continue
+		}
+		addToArchive(yearDir, p, date.Year(), 0)
+
+		feed, ok := yearDir.object.(renderTree)[feedFile]
+		if !ok {
+			feed = &render{
+				t:      renderTypeFeed,
+				object: &postFeed{Subtitle: year, Dir: year},
+				parent: yearDir,
+			}
+			yearDir.object.(renderTree)[feedFile] = feed
+		}
+		pf := feed.object.(*postFeed)
+		pf.Posts = append(pf.Posts, p)
+
+		month := strconv.Itoa(int(date.Month()))
+		if monthDir, ok := yearDir.object.(renderTree)[month]; ok && monthDir.t == renderTypeDirectory {
+			addToArchive(monthDir, p, date.Year(), date.Month())
+		}
+	}
+}
+
+// addToArchive adds the post to the archive at the index.html of |dir|,
+// replacing the redirect placed there by findOrCreateDirNode.
+func addToArchive(dir *render, p *Post, year int, month time.Month) {
+	rt := dir.object.(renderTree)
+	index := rt["index.html"]
+	if index == nil || index.t == renderTypeRedirect {
+		index = &render{
+			t:      renderTypeArchive,
+			object: &archive{Year: year, Month: month},
+			parent: dir,
+		}
+		rt["index.html"] = index
+	} else if index.t != renderTypeArchive {
+		// A post with the URL fragment "index" takes precedence.
+		return
+	}
+	a := index.object.(*archive)
+	a.Posts = append(a.Posts, p)
+}
+
+// tagIndex is the list of posts that share a tag.
+type tagIndex struct {
+	// The name of the tag, as it was first written in a post's metadata.
+	Name string
+
+	Posts PostList
+}
+
+// tagsDir is the directory under which the per-tag index pages are placed.
+const tagsDir = "tags"
+
+// insertTags groups the posts by tag and places a tagIndex and a postFeed for
+// each one into the renderTree root, at `tags/<tag>/`.
+func insertTags(posts PostList, root *render) error {
+	tags := make(map[string]*tagIndex)
+	var slugs []string
+	for _, p := range posts {
+		for _, tag := range p.Tags {
+			slug := tagSlug(tag)
+			if slug == "" {
+				continue
+			}
+			t, ok := tags[slug]
+			if !ok {
+				t = &tagIndex{Name: tag}
+				tags[slug] = t
+				slugs = append(slugs, slug)
+			}
+			// A post that lists the same tag twice only appears once.
+			if n := len(t.Posts); n == 0 || t.Posts[n-1] != p {
+				t.Posts = append(t.Posts, p)
+			}
+		}
+	}
+
+	for _, slug := range slugs {
+		dir, err := findOrCreateDirNode(path.Join(tagsDir, slug, "index.html"), root)
+		if err != nil {
+			return err
+		}
+		dir.object.(renderTree)["index.html"] = &render{
+			t:      renderTypeTag,
+			object: tags[slug],
+			parent: dir,
+		}
+		dir.object.(renderTree)[feedFile] = &render{
+			t: renderTypeFeed,
+			object: &postFeed{
+				Subtitle: tags[slug].Name,
+				Dir:      path.Join(tagsDir, slug),
+				Posts:    tags[slug].Posts,
+			},
+			parent: dir,
+		}
+	}
+	return nil
+}
+
 // insertPost places the given post into the renderTree root at its appropriate
 // depth for the URL.
 func insertPost(post *Post, root *render) error {
@@ -142,6 +366,47 @@ func findOrCreateDirNode(url string, root *render) (*render, error) {
 	return node, nil
 }
 
+// renderInputs returns the posts from which the render |r| is produced.
+func renderInputs(r *render) PostList {
+	switch o := r.object.(type) {
+	case *Post:
+		return PostList{o}
+	case *postFeed:
+		return o.Posts
+	case *tagIndex:
+		return o.Posts
+	case *indexPage:
+		return o.Posts
+	case *archive:
+		return o.Posts
+	}
+	return nil
+}
+
+// writeRenderInputs writes everything that the contents of the render |r|
+// depend on, other than the blog configuration and the templates, to |w|: its
+// type, the fields of its object, and the checksums of its posts. This is
+// hashed into the keys that decide whether |r| must be rendered again.
+func writeRenderInputs(w io.Writer, r *render) {
+	fmt.Fprintln(w, r.t)
+	switch o := r.object.(type) {
+	case string:
+		fmt.Fprintln(w, o)
+	case *indexPage:
+		fmt.Fprintln(w, o.Number, o.NumPages)
+	case *tagIndex:
+		fmt.Fprintln(w, o.Name)
+	case *archive:
+		fmt.Fprintln(w, o.Year, o.Month)
+	case *postFeed:
+		fmt.Fprintln(w, o.Subtitle, o.Dir)
+	}
+	for _, post := range renderInputs(r) {
+		fmt.Fprintln(w, post.Filename)
+		w.Write(post.checksum)
+	}
+}
+
 func visitPosts(root *render) <-chan *Post {
 	c := make(chan *Post)
 
@@ -163,6 +428,20 @@ func visitPosts(root *render) <-chan *Post {
 	return c
 }
 
+// nodeURL returns the path of the render |r| relative to the root.
+func nodeURL(r *render) string {
+	var parts []string
+	for ; r.parent != nil; r = r.parent {
+		for part, child := range r.parent.object.(renderTree) {
+			if child == r {
+				parts = append([]string{part}, parts...)
+				break
+			}
+		}
+	}
+	return path.Join(parts...)
+}
+
 // nodeDepth returns the number of edges between the given render |r| and its
 // root.
 func nodeDepth(r *render) (i int) {
@@ -18,12 +18,16 @@
 package main
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestRootTree(t *testing.T) {
 	one := &Post{URLFragment: "test_post"}
-	root, err := createRenderTree([]*Post{one})
+	root, err := createRenderTree([]*Post{one}, 0)
 
 	if err != nil {
 		t.Fatal("Unexpected error creating render tree", err)
@@ -42,8 +46,14 @@ func TestRootTree(t *testing.T) {
 		t.Errorf("Root object should be a renderTree, is %v", root.object)
 	}
 
-	if len(contents) != 2 {
-		t.Errorf("Root's renderTree should have 2 object, has %d", len(contents))
+	if len(contents) != 3 {
+		t.Errorf("Root's renderTree should have 3 objects, has %d", len(contents))
+	}
+
+	if node, ok := contents["index.html"]; !ok {
+		t.Errorf("renderTree root does not contain index.html")
+	} else if node.t != renderTypeIndex {
+		t.Errorf("index.html does not have the right type, expected %v, got %v", renderTypeIndex, node.t)
 	}
 
 	if node, ok := contents["feed.xml"]; !ok {
@@ -68,7 +78,7 @@ func TestRootTree(t *testing.T) {
 
 func TestTwoDirs(t *testing.T) {
 	post := &Post{URLFragment: "test_post", Date: "14 October 2012"}
-	root, err := createRenderTree([]*Post{post})
+	root, err := createRenderTree([]*Post{post}, 0)
 
 	if err != nil {
 		t.Fatal("Unexpected error creating render tree", err)
@@ -79,8 +89,8 @@ func TestTwoDirs(t *testing.T) {
 		t.Errorf("Root object should be a render tree, is %v", root.object)
 	}
 
-	if len(contents) != 2 {
-		t.Errorf("Root's renderTree should have 1 object, has %d", len(contents))
+	if len(contents) != 3 {
+		t.Errorf("Root's renderTree should have 3 objects, has %d", len(contents))
 	}
 
 	year, ok := contents["2012"]
@@ -99,6 +109,23 @@ func TestTwoDirs(t *testing.T) {
 		t.Fatalf("Year should be a renderTree, got %v", contents)
 	}
 
+	feed, ok := contents[feedFile]
+	if !ok {
+		t.Fatalf("Year feed not present")
+	}
+	if feed.t != renderTypeFeed {
+		t.Errorf("Year feed should be a feed, is %v", feed.t)
+	}
+	if pf := feed.object.(*postFeed); len(pf.Posts) != 1 || pf.Posts[0] != post || pf.Subtitle != "2012" {
+		t.Errorf("Year feed should contain %v for 2012, got %v for %q", post, pf.Posts, pf.Subtitle)
+	}
+
+	if index, ok := contents["index.html"]; !ok || index.t != renderTypeArchive {
+		t.Errorf("Year index.html should be an archive, is %v", index)
+	} else if a := index.object.(*archive); a.Year != 2012 || a.Month != 0 || len(a.Posts) != 1 || a.Posts[0] != post {
+		t.Errorf("Year archive should contain %v for 2012, got %v", post, a)
+	}
+
 	month, ok := contents["10"]
 	if !ok {
 		t.Fatalf("Month directory not present")
@@ -115,6 +142,12 @@ func TestTwoDirs(t *testing.T) {
 		t.Fatalf("Month should be a renderTree, got %v", contents)
 	}
 
+	if index, ok := contents["index.html"]; !ok || index.t != renderTypeArchive {
+		t.Errorf("Month index.html should be an archive, is %v", index)
+	} else if a := index.object.(*archive); a.Year != 2012 || a.Month != time.October || len(a.Posts) != 1 || a.Posts[0] != post {
+		t.Errorf("Month archive should contain %v for October 2012, got %v", post, a)
+	}
+
 	postRender, ok := contents["test_post.html"]
 	if !ok {
 		t.Fatalf("Test post not present")
@@ -132,6 +165,112 @@ func TestTwoDirs(t *testing.T) {
 	}
 }
 
+func TestTags(t *testing.T) {
+	one := &Post{URLFragment: "one", Tags: []string{"Go", "web"}}
+	two := &Post{URLFragment: "two", Tags: []string{"go", "Go"}}
+	three := &Post{URLFragment: "three"}
+	root, err := createRenderTree([]*Post{one, two, three}, 0)
+	if err != nil {
+		t.Fatal("Unexpected error creating render tree", err)
+	}
+
+	tags, ok := root.object.(renderTree)[tagsDir]
+	if !ok {
+		t.Fatalf("renderTree root does not contain %s", tagsDir)
+	}
+	if tags.t != renderTypeDirectory {
+		t.Fatalf("%s should be a directory, is %v", tagsDir, tags.t)
+	}
+
+	expected := map[string]PostList{
+		"go":  {one, two},
+		"web": {one},
+	}
+	// Each tag has its own directory, and the tags directory has an index.html.
+	contents := tags.object.(renderTree)
+	if len(contents) != len(expected)+1 {
+		t.Errorf("Tags renderTree should have %d objects, has %d", len(expected)+1, len(contents))
+	}
+	for slug, posts := range expected {
+		dir, ok := contents[slug]
+		if !ok {
+			t.Errorf("Tag directory %q not present", slug)
+			continue
+		}
+		node, ok := dir.object.(renderTree)["index.html"]
+		if !ok {
+			t.Errorf("Tag directory %q does not contain index.html", slug)
+			continue
+		}
+		if node.t != renderTypeTag {
+			t.Errorf("Tag %q should be a tag, is %v", slug, node.t)
+		}
+		if url := nodeURL(node); url != "tags/"+slug+"/index.html" {
+			t.Errorf("Tag %q has wrong URL %q", slug, url)
+		}
+		tag := node.object.(*tagIndex)
+		if !reflect.DeepEqual(tag.Posts, posts) {
+			t.Errorf("Tag %q should have posts %v, got %v", slug, posts, tag.Posts)
+		}
+
+		feed, ok := dir.object.(renderTree)[feedFile]
+		if !ok {
+			t.Errorf("Tag directory %q does not contain %s", slug, feedFile)
+			continue
+		}
+		if feed.t != renderTypeFeed {
+			t.Errorf("Tag %q feed should be a feed, is %v", slug, feed.t)
+		}
+		if pf := feed.object.(*postFeed); !reflect.DeepEqual(pf.Posts, posts) || pf.Dir != "tags/"+slug {
+			t.Errorf("Tag %q feed should have posts %v in %q, got %v in %q", slug, posts, "tags/"+slug, pf.Posts, pf.Dir)
+		}
+	}
+	if name := contents["go"].object.(renderTree)["index.html"].object.(*tagIndex).Name; name != "Go" {
+		t.Errorf("Tag name should be %q, got %q", "Go", name)
+	}
+}
+
+func TestIndexPages(t *testing.T) {
+	var posts PostList
+	for i := 1; i <= 5; i++ {
+		posts = append(posts, &Post{URLFragment: fmt.Sprintf("post%d", i), Date: fmt.Sprintf("%d January 2012", i)})
+	}
+	root, err := createRenderTree(posts, 2)
+	if err != nil {
+		t.Fatal("Unexpected error creating render tree", err)
+	}
+
+	expected := map[string]PostList{
+		"index.html":        {posts[4], posts[3]},
+		"page/2/index.html": {posts[2], posts[1]},
+		"page/3/index.html": {posts[0]},
+	}
+	for url, pagePosts := range expected {
+		node := root
+		for _, part := range strings.Split(url, "/") {
+			child, ok := node.object.(renderTree)[part]
+			if !ok {
+				t.Fatalf("Index page %q not present", url)
+			}
+			node = child
+		}
+		if node.t != renderTypeIndex {
+			t.Fatalf("Index page %q should be an index, is %v", url, node.t)
+		}
+		page := node.object.(*indexPage)
+		if page.NumPages != 3 {
+			t.Errorf("Index page %q should have 3 pages, has %d", url, page.NumPages)
+		}
+		if !reflect.DeepEqual(page.Posts, pagePosts) {
+			t.Errorf("Index page %q should have posts %v, got %v", url, pagePosts, page.Posts)
+		}
+	}
+
+	if _, ok := root.object.(renderTree)[pagesDir].object.(renderTree)["1"]; ok {
+		t.Errorf("The first index page should only be at the root")
+	}
+}
+
 func TestVisitor(t *testing.T) {
 	root := &render{
 		t: renderTypeDirectory,
@@ -18,48 +18,201 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"html"
+	"io"
+	"io/fs"
 	"net/http"
+	"os"
+	"os/signal"
+	"path"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
 var (
-	serverPollWait = flag.Int("server-poll-time", 30, "The time in seconds that the server waits before polling the directory for changes.")
+	serveDrafts = flag.Bool("drafts", false, "Include draft posts and posts dated in the future when running the server.")
+	liveReload  = flag.Bool("livereload", false, "Reload the pages open in the browser when the blog changes. This is always on with -drafts.")
+
+	// The server used to poll the posts directory for changes. It now watches
+	// the blog instead, but the flag is still accepted so that existing command
+	// lines keep working.
+	serverPollWait = flag.Int("server-poll-time", 30, "Deprecated and ignored: the server rebuilds the blog when its files change and when scheduled posts are published.")
+)
+
+const (
+	// The time limits for reading a request and writing its response, and for
+	// keeping an idle connection open.
+	serverReadTimeout  = 10 * time.Second
+	serverWriteTimeout = 30 * time.Second
+	serverIdleTimeout  = 2 * time.Minute
+
+	// The time to wait for the requests in progress to finish when the server is
+	// shut down.
+	shutdownTimeout = 10 * time.Second
 )
 
 type blogServer struct {
-	blog *Blog
+	// The *Blog that is served, which is replaced when the configuration file
+	// changes.
+	blog atomic.Value
 
 	mu    *sync.RWMutex
 	posts PostList
 	r     *render
+
+	// The error reading the configuration file, if it changed and could not be
+	// read. The previous configuration is served until it is fixed.
+	configErr error
+
+	// The error from the last rebuild, if the posts could not be read, in which
+	// case the previous posts are served.
+	buildErr error
+
+	// The errors from the last rebuild for the posts that could not be read or
+	// rendered. The previous version of each post, if any, is kept so that its
+	// page can show the error.
+	postErrs []*postError
+
+	// The date of the next scheduled post, and the timer that rebuilds the
+	// posts to publish it, if there is one. The timer sends to publish, which
+	// the watcher receives from to rebuild the blog.
+	publishAt    time.Time
+	publishTimer *time.Timer
+	publish      chan struct{}
+
+	// The rendered pages, which are cleared when the render tree is rebuilt.
+	cache *renderCache
+
+	// Watches the files of the blog, or nil if they are not watched.
+	watcher *blogWatcher
+
+	// Reloads the open pages when the blog changes, if liveReload is set.
+	reload     *reloader
+	liveReload bool
+
+	// Where requests are logged, or nil if they are not.
+	accessLog io.Writer
+
+	// The request counts and render times.
+	metrics *serverMetrics
 }
 
 // StartBlogServer runs the program's web server given the blog located
-// at |blogRoot|.
+// at |blogRoot|, until it receives SIGINT or SIGTERM. The requests in progress
+// are then allowed to finish before it returns.
 func StartBlogServer(blog *Blog) error {
-	server := &blogServer{
-		blog: blog,
-		mu:   new(sync.RWMutex),
+	server, err := newBlogServer(blog)
+	if err != nil {
+		return err
 	}
 
-	err := server.buildPosts()
+	server.watcher, err = newBlogWatcher(blog)
 	if err != nil {
 		return err
 	}
-	go server.pollPostChanges()
+	defer server.watcher.Close()
+	go server.watcher.run(server.onChange, server.publish)
+	defer server.schedulePublish(time.Time{})
+
+	if p := blog.AccessLog(); p != "" {
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		server.accessLog = f
+	}
+
+	srv := server.newHTTPServer(blog.ListenAddr())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	errc := make(chan error, 1)
+	go func() {
+		cert, key := blog.TLSFiles()
+		switch {
+		case cert == "" && key == "":
+			fmt.Printf("Starting blog server on http://%s\n", srv.Addr)
+			errc <- srv.ListenAndServe()
+		case cert == "" || key == "":
+			errc <- errors.New("both TLSCertFile and TLSKeyFile must be set to use TLS")
+		default:
+			fmt.Printf("Starting blog server on https://%s\n", srv.Addr)
+			errc <- srv.ListenAndServeTLS(cert, key)
+		}
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case sig := <-signals:
+		fmt.Printf("Received %v, shutting down\n", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
+
+// newHTTPServer returns the HTTP server for |b| that listens on |addr|.
+func (b *blogServer) newHTTPServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	if b.liveReload {
+		mux.Handle(reloadPath, b.reload)
+	}
+	mux.Handle(StaticFilesDir, http.StripPrefix(StaticFilesDir, http.HandlerFunc(b.serveStatic)))
+	mux.HandleFunc(metricsPath, b.serveMetrics)
+	mux.Handle("/", b)
+
+	var handler http.Handler = mux
+	if b.accessLog != nil {
+		handler = &accessLogger{w: b.accessLog, handler: mux}
+	}
+
+	srv := &http.Server{
+		Addr:         addr,
+		Handler:      handler,
+		ReadTimeout:  serverReadTimeout,
+		WriteTimeout: serverWriteTimeout,
+		IdleTimeout:  serverIdleTimeout,
+	}
+	srv.RegisterOnShutdown(b.reload.close)
+	return srv
+}
 
-	if blog.StaticFilesDir() != "" {
-		http.Handle(StaticFilesDir, http.StripPrefix(StaticFilesDir, http.FileServer(http.Dir(blog.StaticFilesDir()))))
+// newBlogServer creates a server for |blog| and builds its posts.
+func newBlogServer(blog *Blog) (*blogServer, error) {
+	server := &blogServer{
+		mu:         new(sync.RWMutex),
+		cache:      newRenderCache(),
+		reload:     newReloader(),
+		publish:    make(chan struct{}, 1),
+		liveReload: *liveReload || *serveDrafts,
+		metrics:    newServerMetrics(),
 	}
+	server.blog.Store(blog)
 
-	http.Handle("/", server)
+	if err := server.buildPosts(false); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
 
-	fmt.Printf("Starting blog server on port %d\n", blog.Port())
-	return http.ListenAndServe(fmt.Sprintf(":%d", blog.Port()), nil)
+// getBlog returns the configuration of the blog that is being served.
+func (b *blogServer) getBlog() *Blog {
+	return b.blog.Load().(*Blog)
 }
 
 func (b *blogServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
@@ -79,6 +232,7 @@ func (b *blogServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		if child, ok := node.object.(renderTree)[part]; ok {
 			node = child
 		} else {
+			b.metrics.countRequest("not_found")
 			http.NotFound(rw, req)
 			return
 		}
@@ -89,64 +243,339 @@ func (b *blogServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 
 func (b *blogServer) serveNode(rw http.ResponseWriter, req *http.Request, render *render) {
 	switch render.t {
-	case renderTypePost:
-		post := render.object.(*Post)
-		content, err := RenderPost(post, CreatePageParams(b.blog, render))
+	case renderTypeDirectory:
+		// Render the index.html node of the directory.
+		render = render.object.(renderTree)["index.html"]
+		b.serveNode(rw, req, render)
+	case renderTypeRedirect:
+		b.metrics.countRequest(pageLabel(render.t))
+		http.Redirect(rw, req, render.object.(string), http.StatusMovedPermanently)
+	default:
+		b.metrics.countRequest(pageLabel(render.t))
+		// A post that could not be read is not found, while pages that fail to
+		// render, including posts, are server errors.
+		status := http.StatusInternalServerError
+		name := path.Clean(req.URL.Path)
+		var page *renderedPage
+		var err error
+		if post, ok := render.object.(*Post); ok {
+			if err = b.postError(post); err != nil {
+				status = http.StatusNotFound
+			}
+		}
+		if err == nil {
+			page, err = b.renderNode(name, render)
+		}
+
 		if err != nil {
-			rw.WriteHeader(http.StatusNotFound)
-			fmt.Fprint(rw, err.Error())
+			b.serveError(rw, status, err)
 			return
 		}
-		rw.Write(content)
-	case renderTypeDirectory:
-		// The root element should generate a post list.
-		if render.parent == nil {
-			index, err := CreateIndex(b.posts, b.blog)
-			if err != nil {
-				rw.WriteHeader(http.StatusInternalServerError)
-				fmt.Fprint(rw, err.Error())
-				return
-			}
-			rw.Write(index)
+
+		if render.t == renderTypeFeed {
+			rw.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
 		} else {
-			// Otherwise, render the index.html node.
-			render = render.object.(renderTree)["index.html"]
-			b.serveNode(rw, req, render)
+			rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if errs := b.pageErrors(render); len(errs) > 0 {
+				// Pages with errors are not cached, since the errors are only shown
+				// until the blog is fixed.
+				page = &renderedPage{
+					key:     page.key + "-" + errorsKey(errs),
+					content: injectErrorOverlay(page.content, errs...),
+					modTime: page.modTime,
+				}
+			}
 		}
-	case renderTypeRedirect:
-		http.Redirect(rw, req, render.object.(string), http.StatusMovedPermanently)
-	case renderTypeFeed:
-		posts := render.object.(PostList)
-		content, err := CreateXMLFeed(posts, b.blog)
+		b.servePage(rw, req, "", page)
+	}
+}
+
+// servePage responds with the contents of |page|, compressed if the request
+// accepts it. The |name| of the file, if any, is used for its Content-Type if
+// that is not set.
+func (b *blogServer) servePage(rw http.ResponseWriter, req *http.Request, name string, page *renderedPage) {
+	content, etag := page.content, page.key
+	rw.Header().Add("Vary", "Accept-Encoding")
+	if enc := negotiateEncoding(req.Header.Get("Accept-Encoding")); enc != nil {
+		encoded, err := page.encode(enc)
 		if err != nil {
-			rw.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprint(rw, err.Error())
+			b.serveError(rw, http.StatusInternalServerError, err)
 			return
 		}
-		rw.Write(content)
-	default:
-		rw.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(rw, "Unknown render: %v", render)
+		content = encoded
+		etag += "-" + enc.name
+		rw.Header().Set("Content-Encoding", enc.name)
+	}
+	// ServeContent answers conditional requests using the ETag and the
+	// modification time.
+	rw.Header().Set("ETag", `"`+etag+`"`)
+	http.ServeContent(rw, req, name, page.modTime, bytes.NewReader(content))
+}
+
+// renderNode returns the rendered page for |render|, which is at the URL path
+// |name|, from the cache if it has not changed since it was last rendered. HTML
+// pages include the reload script if live reload is on.
+func (b *blogServer) renderNode(name string, render *render) (*renderedPage, error) {
+	blog := b.getBlog()
+	key, modTime, err := renderCacheKey(blog, render)
+	if err != nil {
+		return nil, err
+	}
+	if page, ok := b.cache.get(render, key); ok {
+		return page, nil
+	}
+	start := time.Now()
+	content, err := renderNode(blog, render)
+	if err != nil {
+		return nil, err
+	}
+	b.metrics.observeRender(pageLabel(render.t), time.Since(start))
+	if render.t != renderTypePost {
+		modTime = b.cache.changedAt(name, key)
+	}
+	if b.liveReload && render.t != renderTypeFeed {
+		content = injectReloadScript(content)
+	}
+	page := &renderedPage{key: key, content: content, modTime: modTime}
+	b.cache.put(render, page)
+	return page, nil
+}
+
+// errorsKey returns a key for the |errs| shown on a page, so that the page's
+// ETag changes with them.
+func errorsKey(errs []error) string {
+	digest := md5.New()
+	for _, err := range errs {
+		fmt.Fprintln(digest, err)
+	}
+	return hex.EncodeToString(digest.Sum(nil))[:8]
+}
+
+// postError returns the error from the last rebuild for |post|, if any.
+func (b *blogServer) postError(post *Post) error {
+	for _, err := range b.postErrs {
+		if err.Filename == post.Filename {
+			return err
+		}
+	}
+	return nil
+}
+
+// pageErrors returns the errors to show on the page for |render|. Every page
+// shows configuration errors, and the index also shows the errors from the
+// last rebuild.
+func (b *blogServer) pageErrors(render *render) []error {
+	var errs []error
+	if b.configErr != nil {
+		errs = append(errs, b.configErr)
+	}
+	if render.t == renderTypeIndex {
+		if b.buildErr != nil {
+			errs = append(errs, b.buildErr)
+		}
+		for _, err := range b.postErrs {
+			errs = append(errs, err)
+		}
 	}
+	return errs
 }
 
-func (b *blogServer) pollPostChanges() {
-	for {
-		time.Sleep(time.Duration(*serverPollWait) * time.Second)
-		if err := b.buildPosts(); err != nil {
-			panic(err.Error())
+const errorPage = `<!DOCTYPE html>
+<html>
+  <head><meta charset="utf-8"><title>Error</title></head>
+  <body>
+    <h1>Error</h1>
+    <pre>%s</pre>
+  </body>
+</html>`
+
+// serveError responds with |status| and a page that shows |err|. With live
+// reload, the page reloads when the blog changes so that it shows the fixed
+// page.
+func (b *blogServer) serveError(rw http.ResponseWriter, status int, err error) {
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(status)
+	page := []byte(fmt.Sprintf(errorPage, html.EscapeString(err.Error())))
+	if b.liveReload {
+		page = injectReloadScript(page)
+	}
+	rw.Write(page)
+}
+
+// serveStatic serves a file from the static files of the blog. The files that
+// are compressed when rendering are also compressed by the server.
+func (b *blogServer) serveStatic(rw http.ResponseWriter, req *http.Request) {
+	b.metrics.countRequest("static")
+	fsys := b.getBlog().staticFS()
+	name := strings.TrimPrefix(path.Clean("/"+req.URL.Path), "/")
+	if isCompressible(name) {
+		if negotiateEncoding(req.Header.Get("Accept-Encoding")) != nil {
+			if page, err := b.staticFile(fsys, name); err == nil {
+				b.servePage(rw, req, name, page)
+				return
+			}
 		}
+		rw.Header().Add("Vary", "Accept-Encoding")
 	}
+	http.FileServer(http.FS(fsys)).ServeHTTP(rw, req)
 }
 
-func (b *blogServer) buildPosts() (err error) {
-	newPosts, err := GetPostsInDirectory(b.blog.GetPostsDir())
+// staticFile returns the contents of the static file |name| in |fsys|, from
+// the cache if it has not changed since it was last read.
+func (b *blogServer) staticFile(fsys fs.FS, name string) (*renderedPage, error) {
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	key, err := fileKey(fsys, name, info)
+	if err != nil {
+		return nil, err
+	}
+	// The key contains spaces, which are not allowed in an ETag.
+	sum := md5.Sum([]byte(key))
+	key = hex.EncodeToString(sum[:])
+
+	if page, ok := b.cache.get(name, key); ok {
+		return page, nil
+	}
+	content, err := fs.ReadFile(fsys, name)
 	if err != nil {
+		return nil, err
+	}
+	page := &renderedPage{key: key, content: content, modTime: info.ModTime()}
+	b.cache.put(name, page)
+	return page, nil
+}
+
+// serveMetrics serves the metrics of the server, if the blog is configured to
+// expose them.
+func (b *blogServer) serveMetrics(rw http.ResponseWriter, req *http.Request) {
+	if !b.getBlog().config.Metrics {
+		http.NotFound(rw, req)
 		return
 	}
+	b.metrics.ServeHTTP(rw, req)
+}
 
-	b.mu.RLock()
-	rebuild := len(newPosts) != len(b.posts)
+// onChange rebuilds the blog after the files in |paths| changed, and reloads
+// the open pages.
+func (b *blogServer) onChange(paths []string) {
+	configChanged := false
+	for _, p := range paths {
+		if p == b.getBlog().configPath {
+			configChanged = b.reloadConfig()
+			break
+		}
+	}
+
+	if err := b.getBlog().invalidateTemplates(); err != nil {
+		fmt.Fprintln(os.Stderr, "Reloading templates:", err)
+	}
+	if err := b.buildPosts(configChanged); err != nil {
+		fmt.Fprintln(os.Stderr, "Could not rebuild blog:", err)
+	}
+	b.reload.broadcast()
+}
+
+// reloadConfig reads the configuration file again and switches the server to
+// the new configuration, returning true if it did. If the file cannot be read,
+// the server keeps the previous configuration and shows the error on its
+// pages.
+func (b *blogServer) reloadConfig() bool {
+	old := b.getBlog()
+	blog, err := ReadBlog(old.configPath)
+
+	b.mu.Lock()
+	b.configErr = err
+	b.mu.Unlock()
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Could not reload blog configuration:", err)
+		return false
+	}
+
+	applyFlags(blog)
+	oldCert, oldKey := old.TLSFiles()
+	if cert, key := blog.TLSFiles(); blog.ListenAddr() != old.ListenAddr() || cert != oldCert || key != oldKey {
+		fmt.Fprintln(os.Stderr, "The server must be restarted to change its address or TLS configuration.")
+	}
+	if blog.AccessLog() != old.AccessLog() {
+		fmt.Fprintln(os.Stderr, "The server must be restarted to change its access log.")
+	}
+	if b.watcher != nil {
+		if err := b.watcher.watchBlog(blog); err != nil {
+			fmt.Fprintln(os.Stderr, "Watching for changes:", err)
+		}
+	}
+	b.blog.Store(blog)
+	fmt.Println("Reloaded blog configuration")
+	return true
+}
+
+// schedulePublish arranges for the blog to be rebuilt at |at|, when the next
+// scheduled post is published, replacing any earlier arrangement. If |at| is
+// the zero time, nothing is scheduled.
+func (b *blogServer) schedulePublish(at time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.publishTimer != nil {
+		b.publishTimer.Stop()
+		b.publishTimer = nil
+	}
+	b.publishAt = at
+	if !at.IsZero() {
+		b.publishTimer = time.AfterFunc(time.Until(at), func() {
+			// A rebuild that is already requested will publish the post too.
+			select {
+			case b.publish <- struct{}{}:
+			default:
+			}
+		})
+	}
+}
+
+// buildPosts reads the posts and rebuilds the render tree if any of them
+// changed, or if |force| is true. Errors for individual posts are logged and
+// kept to be shown by the server, and do not stop the rebuild.
+func (b *blogServer) buildPosts(force bool) error {
+	blog := b.getBlog()
+	newPosts, postErrs, err := readPostsInDirectory(blog.GetPostsDir())
+
+	b.mu.Lock()
+	b.buildErr = err
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if !*serveDrafts {
+		now := time.Now()
+		b.schedulePublish(newPosts.nextScheduled(now))
+		newPosts = newPosts.Published(now)
+	}
+
+	posts, summaryErrs := renderSummaries(blog, newPosts)
+	postErrs = append(postErrs, summaryErrs...)
+	for _, err := range postErrs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Keep the previous version of the posts that have errors, so that their
+	// URLs show the error rather than disappearing.
+	for _, err := range postErrs {
+		for _, old := range b.posts {
+			if old.Filename == err.Filename {
+				posts = append(posts, old)
+				break
+			}
+		}
+	}
+	b.postErrs = postErrs
+
+	rebuild := force || len(posts) != len(b.posts)
 	if !rebuild {
 		for _, p := range b.posts {
 			if !p.IsUpToDate() {
@@ -155,17 +584,16 @@ func (b *blogServer) buildPosts() (err error) {
 			}
 		}
 	}
-	b.mu.RUnlock()
-
-	if rebuild {
-		b.mu.Lock()
-		defer b.mu.Unlock()
+	if !rebuild {
+		return nil
+	}
 
-		b.posts = newPosts
-		b.r, err = createRenderTree(b.posts)
-		if err != nil {
-			return
-		}
+	r, err := createRenderTree(posts, blog.PostsPerPage())
+	if err != nil {
+		return err
 	}
+	b.posts = posts
+	b.r = r
+	b.cache.clear()
 	return nil
 }
@@ -0,0 +1,498 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveTestPage requests |url| from |server| and returns the response.
+func serveTestPage(t *testing.T, server *blogServer, url string) *httptest.ResponseRecorder {
+	t.Helper()
+	rw := httptest.NewRecorder()
+	server.ServeHTTP(rw, httptest.NewRequest("GET", url, nil))
+	return rw
+}
+
+func TestReloadConfig(t *testing.T) {
+	blog := createTestBlog(t)
+	server, err := newBlogServer(blog)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v", err)
+	}
+
+	config, err := os.ReadFile(blog.configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config = []byte(strings.Replace(string(config), `"Title": "Test"`, `"Title": "Renamed"`, 1))
+	if err := os.WriteFile(blog.configPath, config, 0644); err != nil {
+		t.Fatal(err)
+	}
+	server.onChange([]string{blog.configPath})
+
+	page := serveTestPage(t, server, "/post.html").Body.String()
+	if !strings.Contains(page, "<title>Renamed - Post</title>") {
+		t.Errorf("Expected the new title after reloading the configuration, got %q", page)
+	}
+	if server.getBlog() == blog {
+		t.Errorf("Expected the server to use the new configuration")
+	}
+
+	// An invalid configuration keeps the previous one and shows the error.
+	if err := os.WriteFile(blog.configPath, []byte(`{"Title": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server.onChange([]string{blog.configPath})
+
+	page = serveTestPage(t, server, "/post.html").Body.String()
+	if !strings.Contains(page, "<title>Renamed - Post</title>") {
+		t.Errorf("Expected the previous title with an invalid configuration, got %q", page)
+	}
+	if !strings.Contains(page, `id="blackblog-error"`) || !strings.Contains(page, "unexpected EOF") {
+		t.Errorf("Expected the configuration error to be shown, got %q", page)
+	}
+
+	if err := os.WriteFile(blog.configPath, config, 0644); err != nil {
+		t.Fatal(err)
+	}
+	server.onChange([]string{blog.configPath})
+	if page := serveTestPage(t, server, "/post.html").Body.String(); strings.Contains(page, `id="blackblog-error"`) {
+		t.Errorf("Expected the error to be cleared after fixing the configuration, got %q", page)
+	}
+}
+
+func TestRebuildErrors(t *testing.T) {
+	blog := createTestBlog(t)
+	server, err := newBlogServer(blog)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v", err)
+	}
+
+	post := filepath.Join(blog.GetPostsDir(), "post.md")
+	broken := filepath.Join(blog.GetPostsDir(), "broken.md")
+	for _, p := range []string{post, broken} {
+		if err := os.WriteFile(p, []byte("---\ntitle: Post\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server.onChange([]string{post, broken})
+
+	// The post that was broken keeps its URL, which shows the error.
+	rw := serveTestPage(t, server, "/post.html")
+	if rw.Code != http.StatusNotFound || !strings.Contains(rw.Body.String(), "post.md:1: frontmatter is not closed") {
+		t.Errorf("Expected an error page for the broken post, got %d %q", rw.Code, rw.Body.String())
+	}
+
+	// The index shows both errors.
+	rw = serveTestPage(t, server, "/")
+	if rw.Code != http.StatusOK {
+		t.Errorf("Expected the index to be served, got %d", rw.Code)
+	}
+	for _, f := range []string{"post.md:1:", "broken.md:1:"} {
+		if !strings.Contains(rw.Body.String(), f) {
+			t.Errorf("Expected the index to show the error for %s, got %q", f, rw.Body.String())
+		}
+	}
+
+	if err := os.WriteFile(post, []byte("~~ Title: Post\n\nFixed.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(broken); err != nil {
+		t.Fatal(err)
+	}
+	server.onChange([]string{post, broken})
+	rw = serveTestPage(t, server, "/post.html")
+	if rw.Code != http.StatusOK || !strings.Contains(rw.Body.String(), "Fixed.") {
+		t.Errorf("Expected the fixed post, got %d %q", rw.Code, rw.Body.String())
+	}
+	if body := serveTestPage(t, server, "/").Body.String(); strings.Contains(body, `id="blackblog-error"`) {
+		t.Errorf("Expected no errors on the index, got %q", body)
+	}
+}
+
+func TestPostRenderError(t *testing.T) {
+	blog := createTestBlog(t)
+	useTestTemplates(t, blog, map[string]string{
+		"post.html": `{{template "missing" .}}`,
+	})
+	server, err := newBlogServer(blog)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v", err)
+	}
+
+	// A post that was read but cannot be rendered is a server error.
+	if rw := serveTestPage(t, server, "/post.html"); rw.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d for a template error, got %d", http.StatusInternalServerError, rw.Code)
+	}
+}
+
+func TestErrorsWithoutLiveReload(t *testing.T) {
+	blog := createTestBlog(t)
+	server, err := newBlogServer(blog)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v", err)
+	}
+
+	post := filepath.Join(blog.GetPostsDir(), "post.md")
+	if err := os.WriteFile(post, []byte("---\ntitle: Post\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server.onChange([]string{post})
+
+	// The errors are shown without live reload, but the pages do not reload.
+	rw := serveTestPage(t, server, "/post.html")
+	if rw.Code != http.StatusNotFound || !strings.Contains(rw.Body.String(), "frontmatter is not closed") {
+		t.Errorf("Expected an error page for the broken post, got %d %q", rw.Code, rw.Body.String())
+	}
+	if strings.Contains(rw.Body.String(), reloadPath) {
+		t.Errorf("Expected no reload script without live reload, got %q", rw.Body.String())
+	}
+	body := serveTestPage(t, server, "/").Body.String()
+	if !strings.Contains(body, `id="blackblog-error"`) {
+		t.Errorf("Expected the index to show the error, got %q", body)
+	}
+	if strings.Contains(body, reloadPath) {
+		t.Errorf("Expected no reload script without live reload, got %q", body)
+	}
+}
+
+func TestScheduledPosts(t *testing.T) {
+	blog := createTestBlog(t)
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	later := time.Now().AddDate(0, 0, 3).Format("2006-01-02")
+	for name, content := range map[string]string{
+		"later.md":    "~~ Title: Later\n~~ Date: " + later + "\n\nLater.\n",
+		"tomorrow.md": "~~ Title: Tomorrow\n~~ Date: " + tomorrow + "\n\nTomorrow.\n",
+		"draft.md":    "~~ Title: Draft\n~~ Draft: true\n~~ Date: 2000-01-01\n\nDraft.\n",
+	} {
+		if err := os.WriteFile(filepath.Join(blog.GetPostsDir(), name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server, err := newBlogServer(blog)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v", err)
+	}
+	defer server.schedulePublish(time.Time{})
+
+	if rw := serveTestPage(t, server, "/tomorrow.html"); rw.Code != http.StatusNotFound {
+		t.Errorf("Expected the scheduled post to not be served yet, got %d", rw.Code)
+	}
+	// The rebuild is scheduled for the earliest post.
+	if want := parseDate(tomorrow); !server.publishAt.Equal(want) || server.publishTimer == nil {
+		t.Errorf("Expected a rebuild scheduled at %v, got %v", want, server.publishAt)
+	}
+
+	for _, name := range []string{"later.md", "tomorrow.md"} {
+		if err := os.Remove(filepath.Join(blog.GetPostsDir(), name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server.onChange(nil)
+	if !server.publishAt.IsZero() || server.publishTimer != nil {
+		t.Errorf("Expected no rebuild to be scheduled without scheduled posts, got %v", server.publishAt)
+	}
+}
+
+func TestRenderCache(t *testing.T) {
+	blog := createTestBlog(t)
+	server, err := newBlogServer(blog)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v", err)
+	}
+	templates := useTestTemplates(t, blog, map[string]string{
+		"header.html": "<header>",
+		"footer.html": "</header>",
+	})
+	if err := blog.invalidateTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	if body := serveTestPage(t, server, "/post.html").Body.String(); !strings.Contains(body, "Hello.") {
+		t.Fatalf("Expected the post, got %q", body)
+	}
+
+	// The cached page is served until the blog is rebuilt.
+	post := filepath.Join(blog.GetPostsDir(), "post.md")
+	if err := os.WriteFile(post, []byte("~~ Title: Post\n\nChanged.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if body := serveTestPage(t, server, "/post.html").Body.String(); !strings.Contains(body, "Hello.") {
+		t.Errorf("Expected the cached post, got %q", body)
+	}
+	server.onChange([]string{post})
+	if body := serveTestPage(t, server, "/post.html").Body.String(); !strings.Contains(body, "Changed.") {
+		t.Errorf("Expected the changed post after rebuilding, got %q", body)
+	}
+
+	// Changing a template also renders the page again.
+	header := filepath.Join(templates, "header.html")
+	if err := os.WriteFile(header, []byte("<header>New header"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(header, future, future); err != nil {
+		t.Fatal(err)
+	}
+	server.onChange([]string{header})
+	if body := serveTestPage(t, server, "/post.html").Body.String(); !strings.Contains(body, "New header") {
+		t.Errorf("Expected the new header, got %q", body)
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	blog := createTestBlog(t)
+	server, err := newBlogServer(blog)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v", err)
+	}
+
+	rw := serveTestPage(t, server, "/post.html")
+	if want, got := "text/html; charset=utf-8", rw.Header().Get("Content-Type"); want != got {
+		t.Errorf("Expected Content-Type %q, got %q", want, got)
+	}
+	etag := rw.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `"`) {
+		t.Errorf("Expected a strong ETag, got %q", etag)
+	}
+	lastModified := rw.Header().Get("Last-Modified")
+	if lastModified == "" {
+		t.Errorf("Expected a Last-Modified header")
+	}
+
+	for header, value := range map[string]string{
+		"If-None-Match":     etag,
+		"If-Modified-Since": lastModified,
+	} {
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/post.html", nil)
+		req.Header.Set(header, value)
+		server.ServeHTTP(rw, req)
+		if rw.Code != http.StatusNotModified {
+			t.Errorf("%s: expected status %d, got %d", header, http.StatusNotModified, rw.Code)
+		}
+	}
+
+	// Changing the post changes its ETag.
+	post := filepath.Join(blog.GetPostsDir(), "post.md")
+	if err := os.WriteFile(post, []byte("~~ Title: Post\n\nChanged.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server.onChange([]string{post})
+	rw = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/post.html", nil)
+	req.Header.Set("If-None-Match", etag)
+	server.ServeHTTP(rw, req)
+	if rw.Code != http.StatusOK {
+		t.Errorf("Expected status %d for the changed post, got %d", http.StatusOK, rw.Code)
+	}
+	if rw.Header().Get("ETag") == etag {
+		t.Errorf("Expected the ETag to change with the post")
+	}
+
+	// The index changes when a post is removed, even though the remaining posts
+	// are older.
+	renderIndex := func() *renderedPage {
+		t.Helper()
+		page, err := server.renderNode("/", server.r.object.(renderTree)["index.html"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return page
+	}
+	older := filepath.Join(blog.GetPostsDir(), "older.md")
+	if err := os.WriteFile(older, []byte("~~ Title: Older\n\nOlder.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(post, past, past); err != nil {
+		t.Fatal(err)
+	}
+	server.onChange([]string{older})
+	index := renderIndex()
+	if err := os.Remove(older); err != nil {
+		t.Fatal(err)
+	}
+	server.onChange([]string{older})
+	changed := renderIndex()
+	if !changed.modTime.After(index.modTime) {
+		t.Errorf("Expected the index to be modified after %v, got %v", index.modTime, changed.modTime)
+	}
+
+	// Rendering it again with the same posts keeps the time.
+	server.cache.clear()
+	if again := renderIndex(); !again.modTime.Equal(changed.modTime) {
+		t.Errorf("Expected the index to be modified at %v, got %v", changed.modTime, again.modTime)
+	}
+
+	rw = serveTestPage(t, server, "/feed.xml")
+	if want, got := "application/atom+xml; charset=utf-8", rw.Header().Get("Content-Type"); want != got {
+		t.Errorf("Expected feed Content-Type %q, got %q", want, got)
+	}
+}
+
+func TestConditionalGetPagination(t *testing.T) {
+	blog := createTestBlog(t)
+	blog.config.PostsPerPage = 1
+	for name, date := range map[string]string{"post.md": "2020-01-03", "second.md": "2020-01-02"} {
+		content := "~~ Title: " + name + "\n~~ Date: " + date + "\n\nHello.\n"
+		if err := os.WriteFile(filepath.Join(blog.GetPostsDir(), name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server, err := newBlogServer(blog)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v", err)
+	}
+
+	etag := serveTestPage(t, server, "/page/2/").Header().Get("ETag")
+
+	// An older post adds a third page, which the second page now links to
+	// although its own post did not change.
+	third := filepath.Join(blog.GetPostsDir(), "third.md")
+	if err := os.WriteFile(third, []byte("~~ Title: Third\n~~ Date: 2020-01-01\n\nHello.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server.onChange([]string{third})
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/page/2/", nil)
+	req.Header.Set("If-None-Match", etag)
+	server.ServeHTTP(rw, req)
+	if rw.Code != http.StatusOK {
+		t.Errorf("Expected status %d for the second page after adding a page, got %d", http.StatusOK, rw.Code)
+	}
+	if !strings.Contains(rw.Body.String(), "page/3/") {
+		t.Errorf("Expected a link to the third page, got %q", rw.Body.String())
+	}
+}
+
+func TestServerCompression(t *testing.T) {
+	blog := createTestBlog(t)
+	server, err := newBlogServer(blog)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v", err)
+	}
+
+	for _, url := range []string{"/post.html", "/feed.xml"} {
+		want := serveTestPage(t, server, url).Body.String()
+		for _, encoding := range []string{"br", "gzip"} {
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", url, nil)
+			req.Header.Set("Accept-Encoding", encoding)
+			server.ServeHTTP(rw, req)
+
+			if got := rw.Header().Get("Content-Encoding"); encoding != got {
+				t.Errorf("%s: expected Content-Encoding %q, got %q", url, encoding, got)
+				continue
+			}
+			if got := rw.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("%s: expected Vary: Accept-Encoding, got %q", url, got)
+			}
+			if got := decompress(t, encoding, rw.Body.Bytes()); want != got {
+				t.Errorf("%s: %s content does not match: %q", url, encoding, got)
+			}
+		}
+	}
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/blackblog.css", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	server.serveStatic(rw, req)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("Expected status %d for the stylesheet, got %d", http.StatusOK, rw.Code)
+	}
+	if got := rw.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("Expected a gzipped stylesheet, got Content-Encoding %q", got)
+	}
+	if got := rw.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/css") {
+		t.Errorf("Expected the stylesheet's Content-Type, got %q", got)
+	}
+}
+
+func TestLiveReload(t *testing.T) {
+	blog := createTestBlog(t)
+	server, err := newBlogServer(blog)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v", err)
+	}
+
+	// Live reload is off unless it is asked for.
+	handler := server.newHTTPServer("").Handler
+	if body := serveTestPage(t, server, "/post.html").Body.String(); strings.Contains(body, reloadPath) {
+		t.Errorf("Expected no reload script without live reload, got %q", body)
+	}
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest("GET", reloadPath, nil))
+	if rw.Code != http.StatusNotFound {
+		t.Errorf("Expected no reload endpoint without live reload, got %d", rw.Code)
+	}
+
+	server, err = newBlogServer(blog)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v", err)
+	}
+	server.liveReload = true
+	if body := serveTestPage(t, server, "/post.html").Body.String(); !strings.Contains(body, reloadScript) {
+		t.Errorf("Expected the reload script with live reload, got %q", body)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	blog := createTestBlog(t)
+	server, err := newBlogServer(blog)
+	if err != nil {
+		t.Fatalf("Unexpected error creating server: %v", err)
+	}
+	server.liveReload = true
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := server.newHTTPServer(l.Addr().String())
+	go srv.Serve(l)
+
+	// Without keep-alives, the client does not open spare connections, which
+	// the server would wait on as if they were in use.
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	url := "http://" + l.Addr().String()
+	resp, err := client.Get(url + "/post.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	// An open page does not keep the server from shutting down.
+	events, err := client.Get(url + reloadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Errorf("Unexpected error shutting down: %v", err)
+	}
+	if _, err := io.ReadAll(events.Body); err != nil {
+		t.Errorf("Expected the event stream to end, got %v", err)
+	}
+}
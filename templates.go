@@ -0,0 +1,261 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template/parse"
+	"time"
+)
+
+// The name of the optional template that every page is rendered within. If it
+// is not present, pages are wrapped by header.html and footer.html instead.
+const layoutTemplate = "layout.html"
+
+// templateSet holds the templates parsed from the templatesFS, which are
+// loaded on first use and reused until they are invalidated.
+type templateSet struct {
+	mu sync.Mutex
+
+	// The parsed templates, or nil if they have not been loaded.
+	parsed *parsedTemplates
+
+	// The fingerprint of the template files that |parsed| is from.
+	fingerprint string
+}
+
+// parsedTemplates is the result of parsing the template files. Every .html
+// file in the templatesFS, including those in subdirectories, is parsed into a
+// single set and named by its path, e.g.
+// "post.html" or "partials/nav.html". This lets templates share the blocks
+// that any file in the set defines.
+type parsedTemplates struct {
+	set *template.Template
+
+	// The fingerprint of the template files, which identifies this parse.
+	fingerprint string
+
+	// The latest modification time of the template files, which is zero if
+	// they are all built in.
+	modTime time.Time
+
+	// The source of each template, by name.
+	sources map[string]string
+
+	// If there is a layout, the pages composed with it by composePage, by name.
+	// The set is only cloned and never executed in that case, since
+	// html/template cannot clone a template after it has been executed.
+	mu    sync.Mutex
+	pages map[string]*template.Template
+}
+
+// templates returns the template set for the blog, parsing it if it has not
+// been loaded yet.
+func (b *Blog) templates() (*parsedTemplates, error) {
+	b.tpl.mu.Lock()
+	defer b.tpl.mu.Unlock()
+
+	if b.tpl.parsed != nil {
+		return b.tpl.parsed, nil
+	}
+
+	fingerprint, err := templatesFingerprint(b)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := parseTemplates(b)
+	if err != nil {
+		return nil, err
+	}
+	parsed.fingerprint = fingerprint
+	b.tpl.parsed = parsed
+	b.tpl.fingerprint = fingerprint
+	return parsed, nil
+}
+
+// invalidateTemplates discards the parsed template set if any of the template
+// files have changed since it was parsed, so that it is parsed again when it
+// is next used.
+func (b *Blog) invalidateTemplates() error {
+	fingerprint, err := templatesFingerprint(b)
+	if err != nil {
+		return err
+	}
+
+	b.tpl.mu.Lock()
+	defer b.tpl.mu.Unlock()
+	if fingerprint != b.tpl.fingerprint {
+		b.tpl.parsed = nil
+	}
+	return nil
+}
+
+// executeTemplate executes the template |name|, without its ".html"
+// extension, from the blog's template set with |data|.
+func executeTemplate(blog *Blog, name string, data interface{}) ([]byte, error) {
+	t, err := blog.templates()
+	if err != nil {
+		return nil, err
+	}
+	return execute(t.set, name+".html", data)
+}
+
+// hasLayout returns true if the pages are rendered within layout.html.
+func (t *parsedTemplates) hasLayout() bool {
+	return t.set.Lookup(layoutTemplate) != nil
+}
+
+// executePage executes the page template |name|, without its ".html"
+// extension, within the layout with |data|.
+func (t *parsedTemplates) executePage(name string, data interface{}) ([]byte, error) {
+	page, err := t.composePage(name + ".html")
+	if err != nil {
+		return nil, err
+	}
+	return execute(page, layoutTemplate, data)
+}
+
+// composePage returns a copy of the set in which the "content" and "head"
+// blocks of the layout are filled by the page template |name|. Blocks that the
+// page defines override those of the layout. If the page does not define the
+// "content" block, its whole body is used as the content.
+func (t *parsedTemplates) composePage(name string) (*template.Template, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if page, ok := t.pages[name]; ok {
+		return page, nil
+	}
+
+	src, ok := t.sources[name]
+	if !ok {
+		return nil, fmt.Errorf("template %s is not defined", name)
+	}
+
+	page, err := t.set.Clone()
+	if err != nil {
+		return nil, err
+	}
+	// Parse the layout again, since another page in the set may have
+	// redefined its blocks, and then parse the page over it.
+	if _, err := page.New(layoutTemplate).Parse(t.sources[layoutTemplate]); err != nil {
+		return nil, err
+	}
+	var layoutContent *parse.Tree
+	if content := page.Lookup("content"); content != nil {
+		layoutContent = content.Tree
+	}
+	if _, err := page.New(name).Parse(src); err != nil {
+		return nil, fmt.Errorf("template %s: %v", name, err)
+	}
+	if content := page.Lookup("content"); content == nil || content.Tree == layoutContent {
+		if _, err := page.AddParseTree("content", page.Lookup(name).Tree); err != nil {
+			return nil, err
+		}
+	}
+
+	t.pages[name] = page
+	return page, nil
+}
+
+func execute(t *template.Template, name string, data interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := t.ExecuteTemplate(buf, name, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// parseTemplates parses every template file in the templatesFS into a set.
+func parseTemplates(b *Blog) (*parsedTemplates, error) {
+	t := &parsedTemplates{
+		set:     template.New("").Funcs(templateFuncs(b)),
+		sources: make(map[string]string),
+		pages:   make(map[string]*template.Template),
+	}
+	fsys := b.templatesFS()
+	err := walkTemplates(b, fsys, func(name string, info fs.FileInfo) error {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		if _, err := t.set.New(name).Parse(string(data)); err != nil {
+			return fmt.Errorf("template %s: %v", name, err)
+		}
+		if info.ModTime().After(t.modTime) {
+			t.modTime = info.ModTime()
+		}
+		t.sources[name] = string(data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// templatesFingerprint returns a key for the directories that templates are
+// looked up in and for the template files, which changes when any of them
+// changes.
+func templatesFingerprint(b *Blog) (string, error) {
+	digest := md5.New()
+	fmt.Fprintln(digest, b.config.TemplatesDir, b.config.Theme)
+	fsys := b.templatesFS()
+	err := walkTemplates(b, fsys, func(name string, info fs.FileInfo) error {
+		key, err := fileKey(fsys, name, info)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(digest, name, key)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// walkTemplates calls |fn| for each template file in the templates |fsys| of
+// |b|. The static directory of a theme, and the StaticFilesDir if it is inside
+// the TemplatesDir, are skipped, since the files in them are not templates.
+func walkTemplates(b *Blog, fsys fs.FS, fn func(name string, info fs.FileInfo) error) error {
+	skip := map[string]bool{themeStaticDir: true}
+	if b.config.TemplatesDir != "" && b.config.StaticFilesDir != "" {
+		rel, err := filepath.Rel(b.TemplatesDir(), b.StaticFilesDir())
+		if err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			skip[filepath.ToSlash(rel)] = true
+		}
+	}
+
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if skip[name] {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if path.Ext(name) != ".html" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(name, info)
+	})
+}
@@ -0,0 +1,164 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTestTemplates writes |files| to a templates directory for |blog| and
+// configures the blog to use it. It returns the path to the directory.
+func useTestTemplates(t *testing.T, blog *Blog, files map[string]string) string {
+	dir := filepath.Join(filepath.Dir(blog.configPath), "templates")
+	blog.config.TemplatesDir = "./templates"
+	blog.config.StaticFilesDir = "./templates/static"
+
+	for name, contents := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestTemplateSet(t *testing.T) {
+	blog := createTestBlog(t)
+	dir := useTestTemplates(t, blog, map[string]string{
+		"page.html":            `{{define "title"}}Title{{end}}<p>{{template "partials/byline.html" .}}</p>`,
+		"partials/byline.html": `{{template "title"}} by {{.}}`,
+		"static/ignored.html":  `{{ not a template`,
+	})
+
+	out, err := executeTemplate(blog, "page", "Inigo")
+	if err != nil {
+		t.Fatalf("Unexpected error executing template: %v", err)
+	}
+	if want, got := "<p>Title by Inigo</p>", string(out); want != got {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	// The set is not parsed again until a template changes.
+	tpl, _ := blog.templates()
+	if err := blog.invalidateTemplates(); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := blog.templates(); again != tpl {
+		t.Errorf("Template set should be reused when no templates changed")
+	}
+
+	byline := filepath.Join(dir, "partials", "byline.html")
+	if err := os.WriteFile(byline, []byte(`by {{.}} again`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(byline, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if err := blog.invalidateTemplates(); err != nil {
+		t.Fatal(err)
+	}
+	out, err = executeTemplate(blog, "page", "Inigo")
+	if err != nil {
+		t.Fatalf("Unexpected error executing template: %v", err)
+	}
+	if want, got := "<p>by Inigo again</p>", string(out); want != got {
+		t.Errorf("Expected %q after changing a template, got %q", want, got)
+	}
+}
+
+func TestTemplatesSkipStaticFilesDir(t *testing.T) {
+	blog := createTestBlog(t)
+	useTestTemplates(t, blog, map[string]string{
+		"page.html":          `Page`,
+		"assets/broken.html": `{{ not a template`,
+	})
+	blog.config.StaticFilesDir = "./templates/assets"
+
+	out, err := executeTemplate(blog, "page", nil)
+	if err != nil {
+		t.Fatalf("Files in the StaticFilesDir should not be parsed: %v", err)
+	}
+	if want, got := "Page", string(out); want != got {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestTemplateEscaping(t *testing.T) {
+	blog := createTestBlog(t)
+	post := "~~ Title: Fish & <script>Chips</script>\n\nSome *fried* <b>food</b>.\n"
+	if err := os.WriteFile(filepath.Join(blog.GetPostsDir(), "post.md"), []byte(post), 0644); err != nil {
+		t.Fatal(err)
+	}
+	blog.config.GoldmarkConfig.Render.Unsafe = true
+	if err := blog.parseOptions(); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteStaticBlog(blog); err != nil {
+		t.Fatalf("Unexpected error rendering blog: %v", err)
+	}
+
+	for _, f := range []string{"fish_script_chips_script.html", "index.html"} {
+		data, err := os.ReadFile(filepath.Join(blog.GetOutputDir(), f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := string(data)
+		if strings.Contains(out, "<script>") {
+			t.Errorf("%s: title should be escaped, got %q", f, out)
+		}
+		if !strings.Contains(out, "Fish &amp; &lt;script&gt;Chips&lt;/script&gt;") {
+			t.Errorf("%s: expected escaped title, got %q", f, out)
+		}
+		if !strings.Contains(out, "Some <em>fried</em> <b>food</b>.") {
+			t.Errorf("%s: rendered Markdown should not be escaped, got %q", f, out)
+		}
+	}
+}
+
+func TestLayout(t *testing.T) {
+	blog := createTestBlog(t)
+	useTestTemplates(t, blog, map[string]string{
+		"layout.html": `<head>{{block "head" .}}<title>{{.Title}}</title>{{end}}</head>` +
+			`<body>{{block "content" .}}No content{{end}}</body>`,
+		"post.html": `{{define "head"}}<meta property="og:title" content="{{.Post.Title}}">{{end}}` +
+			`{{define "content"}}<h1>{{.Post.Title}}</h1>{{.Content}}{{end}}`,
+		"index.html":  `{{range .Posts}}<p>{{.Title}}</p>{{end}}`,
+		"header.html": `Unused header`,
+	})
+
+	posts, err := GetPostsInDirectory(blog.GetPostsDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := RenderPost(posts[0], CreatePageParams(blog, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error rendering post: %v", err)
+	}
+	want := `<head><meta property="og:title" content="Post"></head><body><h1>Post</h1><p>Hello.</p>
+</body>`
+	if got := string(out); want != got {
+		t.Errorf("Expected post %q, got %q", want, got)
+	}
+
+	// The index does not define any blocks, so its body is the content.
+	out, err = CreateIndex(&indexPage{Number: 1, NumPages: 1, Posts: posts}, CreatePageParams(blog, nil))
+	if err != nil {
+		t.Fatalf("Unexpected error rendering index: %v", err)
+	}
+	want = `<head><title>Posts</title></head><body><p>Post</p></body>`
+	if got := string(out); want != got {
+		t.Errorf("Expected index %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"crypto/md5"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// The default templates and static files, which are built into the binary.
+//
+//go:embed templates
+var builtinFiles embed.FS
+
+// builtinTemplates is the templates directory of builtinFiles.
+var builtinTemplates = mustSub(builtinFiles, "templates")
+
+// builtinFingerprint returns a checksum of all of the built-in files, which
+// changes when they are changed by a new version of Blackblog.
+func builtinFingerprint() (string, error) {
+	digest := md5.New()
+	err := fs.WalkDir(builtinFiles, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(builtinFiles, name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(digest, name, len(data))
+		digest.Write(data)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// The directory of a theme, or of the built-in templates, that contains the
+// static files.
+const themeStaticDir = "static"
+
+// templatesFS returns the file system from which the templates are loaded.
+// A template is looked up first in the TemplatesDir, then in the Theme, and
+// then in the built-in templates, so a blog only needs to provide the
+// templates that it customizes.
+func (b *Blog) templatesFS() fs.FS {
+	var layers layeredFS
+	if b.config.TemplatesDir != "" {
+		layers = append(layers, os.DirFS(b.TemplatesDir()))
+	}
+	if b.config.Theme != "" {
+		layers = append(layers, os.DirFS(b.ThemeDir()))
+	}
+	return append(layers, builtinTemplates)
+}
+
+// staticFS returns the file system from which static files are copied or
+// served, which is looked up in the same order as templatesFS: first the
+// StaticFilesDir, then the Theme's static directory, and then the built-in
+// static files.
+func (b *Blog) staticFS() fs.FS {
+	var layers layeredFS
+	if b.config.StaticFilesDir != "" {
+		layers = append(layers, os.DirFS(b.StaticFilesDir()))
+	}
+	if b.config.Theme != "" {
+		layers = append(layers, os.DirFS(filepath.Join(b.ThemeDir(), themeStaticDir)))
+	}
+	return append(layers, mustSub(builtinTemplates, themeStaticDir))
+}
+
+func mustSub(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// fileKey returns a key for the file |name| in |fsys| that changes when the
+// file does. This is its size and modification time, or the checksum of its
+// contents for the built-in files, which have no modification time.
+func fileKey(fsys fs.FS, name string, info fs.FileInfo) (string, error) {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf("%d %d", info.Size(), info.ModTime().UnixNano()), nil
+	}
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return "", err
+	}
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// layeredFS is a file system that looks up each file in a list of layers, in
+// order, so that the files in earlier layers override those in later ones.
+// Directories are merged across the layers. Layers that do not exist are
+// skipped.
+type layeredFS []fs.FS
+
+func (l layeredFS) Open(name string) (fs.File, error) {
+	for _, layer := range l {
+		f, err := layer.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir returns the entries of the directory |name| in all of the layers.
+// An entry in an earlier layer hides an entry of the same name in later ones.
+func (l layeredFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	seen := make(map[string]bool)
+	found := false
+	for _, layer := range l {
+		layerEntries, err := fs.ReadDir(layer, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		found = true
+		for _, entry := range layerEntries {
+			if !seen[entry.Name()] {
+				seen[entry.Name()] = true
+				entries = append(entries, entry)
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
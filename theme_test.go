@@ -0,0 +1,153 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLayeredFS(t *testing.T) {
+	l := layeredFS{
+		fstest.MapFS{
+			"a.html":     {Data: []byte("top a")},
+			"dir/b.html": {Data: []byte("top b")},
+		},
+		os.DirFS(filepath.Join(t.TempDir(), "missing")),
+		fstest.MapFS{
+			"a.html":     {Data: []byte("bottom a")},
+			"c.html":     {Data: []byte("bottom c")},
+			"dir/d.html": {Data: []byte("bottom d")},
+		},
+	}
+
+	files := map[string]string{
+		"a.html":     "top a",
+		"c.html":     "bottom c",
+		"dir/b.html": "top b",
+		"dir/d.html": "bottom d",
+	}
+	for name, want := range files {
+		if got, err := fs.ReadFile(l, name); err != nil || string(got) != want {
+			t.Errorf("Expected %s to be %q, got %q: %v", name, want, got, err)
+		}
+	}
+	if _, err := l.Open("e.html"); !os.IsNotExist(err) {
+		t.Errorf("Expected a missing file to not exist, got %v", err)
+	}
+
+	var walked []string
+	fs.WalkDir(l, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !d.IsDir() {
+			walked = append(walked, p)
+		}
+		return nil
+	})
+	if want, got := "a.html c.html dir/b.html dir/d.html", strings.Join(walked, " "); want != got {
+		t.Errorf("Expected to walk %q, got %q", want, got)
+	}
+}
+
+func TestTheme(t *testing.T) {
+	blog := createTestBlog(t)
+	root := filepath.Dir(blog.configPath)
+	blog.config.Theme = "./theme"
+	useTestTemplates(t, blog, map[string]string{
+		"footer.html":        "<footer>Blog footer</footer>",
+		"static/custom.css":  "blog",
+		"static/overlap.css": "blog",
+	})
+
+	theme := map[string]string{
+		"theme/header.html":        "<header>Theme header</header>",
+		"theme/footer.html":        "<footer>Theme footer</footer>",
+		"theme/static/overlap.css": "theme",
+		"theme/static/theme.css":   "theme",
+	}
+	for name, contents := range theme {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := WriteStaticBlog(blog); err != nil {
+		t.Fatalf("Unexpected error rendering blog: %v", err)
+	}
+	out := blog.GetOutputDir()
+
+	// The header is from the theme, the footer from the blog, and the post
+	// template is built in.
+	data, err := os.ReadFile(filepath.Join(out, "post.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Theme header", "Blog footer", `id="post-title"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected post to contain %q, got %q", want, data)
+		}
+	}
+
+	static := map[string]string{
+		"custom.css":    "blog",
+		"overlap.css":   "blog",
+		"theme.css":     "theme",
+		"blackblog.css": "",
+	}
+	for name, want := range static {
+		data, err := os.ReadFile(filepath.Join(out, "static", name))
+		if err != nil {
+			t.Errorf("Expected static file %s: %v", name, err)
+		} else if want != "" && string(data) != want {
+			t.Errorf("Expected static file %s to be %q, got %q", name, want, data)
+		}
+	}
+}
+
+func TestBuiltinTemplates(t *testing.T) {
+	blog := createTestBlog(t)
+	blog.config.TemplatesDir = ""
+	blog.config.StaticFilesDir = ""
+
+	if err := WriteStaticBlog(blog); err != nil {
+		t.Fatalf("Unexpected error rendering blog: %v", err)
+	}
+	out := blog.GetOutputDir()
+
+	data, err := os.ReadFile(filepath.Join(out, "post.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `id="post-title"`) {
+		t.Errorf("Expected post to use the built-in template, got %q", data)
+	}
+
+	builtin, err := fs.ReadFile(builtinFiles, "templates/static/blackblog.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	css := filepath.Join(out, "static", "blackblog.css")
+	if data, err := os.ReadFile(css); err != nil || string(data) != string(builtin) {
+		t.Errorf("Expected built-in static file to be copied: %v", err)
+	}
+
+	// The copied static files can be written again.
+	*forceRender = true
+	defer func() { *forceRender = false }()
+	if err := WriteStaticBlog(blog); err != nil {
+		t.Fatalf("Unexpected error rendering blog again: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// The time to wait for more changes after a file changes before reporting
+// them, since saving a file often produces several events.
+const watchDelay = 100 * time.Millisecond
+
+// blogWatcher watches the files that a blog is built from for changes: the
+// posts, the templates, the theme, the static files, and the configuration
+// file.
+type blogWatcher struct {
+	w *fsnotify.Watcher
+
+	// The configuration file, which is watched in its directory.
+	configPath string
+
+	// The directory to which the blog is rendered, which is not watched.
+	outputDir string
+
+	// The directories whose files are watched. After the watcher is created,
+	// this is only used by run and by the onChange function that it calls.
+	dirs map[string]bool
+}
+
+// newBlogWatcher starts watching the files of |blog|.
+func newBlogWatcher(blog *Blog) (*blogWatcher, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	bw := &blogWatcher{
+		w:          w,
+		configPath: filepath.Clean(blog.configPath),
+		dirs:       make(map[string]bool),
+	}
+	if err := bw.watchBlog(blog); err != nil {
+		w.Close()
+		return nil, err
+	}
+	if err := w.Add(filepath.Dir(bw.configPath)); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return bw, nil
+}
+
+// watchBlog watches the directories of |blog|, in addition to those that are
+// already watched. This is called again when the configuration changes, and
+// must then be called from the onChange function passed to run.
+func (bw *blogWatcher) watchBlog(blog *Blog) error {
+	bw.outputDir = filepath.Clean(blog.GetOutputDir())
+
+	dirs := []string{blog.GetPostsDir()}
+	if blog.config.TemplatesDir != "" {
+		dirs = append(dirs, blog.TemplatesDir())
+	}
+	if blog.config.Theme != "" {
+		dirs = append(dirs, blog.ThemeDir())
+	}
+	if blog.config.StaticFilesDir != "" {
+		dirs = append(dirs, blog.StaticFilesDir())
+	}
+	for _, dir := range dirs {
+		if err := bw.watchDir(dir); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchDir watches the files in |dir| and in its subdirectories. The output
+// directory and hidden directories, like .git, are skipped.
+func (bw *blogWatcher) watchDir(dir string) error {
+	dir = filepath.Clean(dir)
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if p != dir && (p == bw.outputDir || strings.HasPrefix(info.Name(), ".")) {
+			return filepath.SkipDir
+		}
+		if bw.dirs[p] {
+			return nil
+		}
+		if err := bw.w.Add(p); err != nil {
+			return err
+		}
+		bw.dirs[p] = true
+		return nil
+	})
+}
+
+// isWatched returns true if a change to the file at |p| should be reported.
+func (bw *blogWatcher) isWatched(p string) bool {
+	return p == bw.configPath || bw.dirs[filepath.Dir(p)]
+}
+
+// run calls |onChange| with the paths of the files that changed for each batch
+// of changes, until the watcher is closed. It also calls |onChange| when it
+// receives from |rebuild|, with any changes that are pending, so that the
+// rebuilds that are not caused by changes, like when a scheduled post is
+// published, do not run at the same time as others.
+func (bw *blogWatcher) run(onChange func(paths []string), rebuild <-chan struct{}) {
+	var pending []string
+	var delay <-chan time.Time
+	for {
+		select {
+		case event, ok := <-bw.w.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod || !bw.isWatched(event.Name) {
+				continue
+			}
+			// Watch new directories, e.g. for posts in a new subdirectory.
+			if event.Op&fsnotify.Create != 0 && bw.dirs[filepath.Dir(event.Name)] {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := bw.watchDir(event.Name); err != nil {
+						fmt.Fprintln(os.Stderr, "Watching for changes:", err)
+					}
+				}
+			}
+			pending = append(pending, event.Name)
+			delay = time.After(watchDelay)
+		case err, ok := <-bw.w.Errors:
+			if !ok {
+				return
+			}
+			fmt.Fprintln(os.Stderr, "Watching for changes:", err)
+		case <-delay:
+			onChange(pending)
+			pending = nil
+			delay = nil
+		case <-rebuild:
+			onChange(pending)
+			pending = nil
+			delay = nil
+		}
+	}
+}
+
+func (bw *blogWatcher) Close() error {
+	return bw.w.Close()
+}
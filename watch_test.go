@@ -0,0 +1,91 @@
+// Copyright 2026 Blue Static <https://www.bluestatic.org>
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBlogWatcher(t *testing.T) {
+	blog := createTestBlog(t)
+	root := filepath.Dir(blog.configPath)
+	if err := os.MkdirAll(blog.GetOutputDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := newBlogWatcher(blog)
+	if err != nil {
+		t.Fatalf("Unexpected error watching blog: %v", err)
+	}
+	defer watcher.Close()
+
+	changes := make(chan []string, 10)
+	rebuild := make(chan struct{})
+	go watcher.run(func(paths []string) {
+		changes <- paths
+	}, rebuild)
+	expectChange := func(p string) {
+		t.Helper()
+		select {
+		case paths := <-changes:
+			for _, changed := range paths {
+				if changed == p {
+					return
+				}
+			}
+			t.Errorf("Expected a change to %s, got %v", p, paths)
+		case <-time.After(5 * time.Second):
+			t.Errorf("Expected a change to %s", p)
+		}
+	}
+	write := func(p string) {
+		t.Helper()
+		if err := os.WriteFile(p, []byte("~~ Title: Changed\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	post := filepath.Join(blog.GetPostsDir(), "post.md")
+	write(post)
+	expectChange(post)
+
+	write(blog.configPath)
+	expectChange(blog.configPath)
+
+	// Files in new directories are watched.
+	dir := filepath.Join(blog.GetPostsDir(), "2026")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	expectChange(dir)
+	post = filepath.Join(dir, "new.md")
+	write(post)
+	expectChange(post)
+
+	// Other files next to the configuration file are not watched, nor is the
+	// output directory.
+	write(filepath.Join(root, "notes.txt"))
+	write(filepath.Join(blog.GetOutputDir(), "index.html"))
+	select {
+	case paths := <-changes:
+		t.Errorf("Expected no changes, got %v", paths)
+	case <-time.After(5 * watchDelay):
+	}
+
+	// A rebuild can be requested without any changes.
+	rebuild <- struct{}{}
+	select {
+	case paths := <-changes:
+		if len(paths) != 0 {
+			t.Errorf("Expected a rebuild without changes, got %v", paths)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected a rebuild")
+	}
+}
@@ -19,158 +19,350 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	forceRender = flag.Bool("force", false, "When rendering, write every output file even if its inputs have not changed since the last render.")
+	dryRun      = flag.Bool("dry-run", false, "When rendering, list the files from the last render that are no longer produced instead of removing them.")
+	renderJobs  = flag.Int("jobs", runtime.GOMAXPROCS(0), "When rendering, the number of files to render concurrently.")
 )
 
 // WriteStaticBlog takes a given blog and renders its output as static HTML
 // files, according to the configuration.
 func WriteStaticBlog(blog *Blog) error {
-	posts, err := GetPostsInDirectory(blog.GetPostsDir())
+	posts, postErrs, err := readPostsInDirectory(blog.GetPostsDir())
 	if err != nil {
 		return errors.New("Get posts: " + err.Error())
 	}
+	posts, summaryErrs := renderSummaries(blog, posts.Published(time.Now()))
+	// A broken post is skipped, rather than failing the rest of the blog.
+	for _, err := range append(postErrs, summaryErrs...) {
+		fmt.Fprintln(os.Stderr, "Skipping post:", err)
+	}
 
-	renderTree, err := createRenderTree(posts)
+	renderTree, err := createRenderTree(posts, blog.PostsPerPage())
 	if err != nil {
 		return errors.New("Render posts:" + err.Error())
 	}
 
 	dest := blog.GetOutputDir()
+	if err := checkOutputDir(blog); err != nil {
+		return err
+	}
 	if err := os.Mkdir(dest, 0755); err != nil && !os.IsExist(err) {
 		return errors.New("Creating output directory: " + err.Error())
 	}
 
-	if err := writeRenderTree(blog.GetOutputDir(), blog, renderTree); err != nil {
+	buildKey, err := computeBuildKey(blog)
+	if err != nil {
+		return errors.New("Reading configuration: " + err.Error())
+	}
+	manifest := loadManifest(dest, buildKey)
+	manifest.force = *forceRender
+
+	if err := writeRenderTree(blog.GetOutputDir(), blog, renderTree, manifest, *renderJobs); err != nil {
 		return errors.New("Write files: " + err.Error())
 	}
 
-	index, err := CreateIndex(posts, blog)
-	var f *os.File
-	if err == nil {
-		f, err = os.Create(path.Join(blog.GetOutputDir(), "index.html"))
+	if err := copyFS(path.Join(dest, StaticFilesDir), blog.staticFS(), blog, manifest); err != nil {
+		return errors.New("Copying static files: " + err.Error())
 	}
-	if err != nil {
-		return errors.New("Creating index: " + err.Error())
+
+	if err := pruneOutputDir(os.Stdout, blog, manifest, *dryRun); err != nil {
+		return errors.New("Removing stale files: " + err.Error())
 	}
-	defer f.Close()
-	f.Write(index)
 
-	if blog.StaticFilesDir() != "" {
-		if err := copyDir(path.Join(dest, StaticFilesDir), blog.StaticFilesDir()); err != nil {
-			return errors.New("Copying static files: " + err.Error())
-		}
+	if err := manifest.save(); err != nil {
+		return errors.New("Writing manifest: " + err.Error())
 	}
 
 	return nil
 }
 
 // writeRenderTree takes a root render object and writes out a rendered site
-// to the given destination path.
-func writeRenderTree(dest string, blog *Blog, root *render) error {
+// to the given destination path, rendering up to |jobs| files concurrently.
+// Files that are up-to-date in the manifest are not rendered again.
+func writeRenderTree(dest string, blog *Blog, root *render, manifest *buildManifest, jobs int) error {
 	if root.t != renderTypeDirectory {
 		return fmt.Errorf("writeRenderTree for %q: not a directory", blog.GetOutputDir())
 	}
 
-	// Iterate over this renderTree's subnodes.
+	var files []renderFile
+	if err := createRenderDirs(dest, root, &files); err != nil {
+		return err
+	}
+
+	// Sort the files so that errors are reported in a deterministic order.
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].path < files[j].path
+	})
+
+	if jobs < 1 {
+		jobs = 1
+	}
+	errs := make([]error, len(files))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				errs[i] = writeRenderFile(blog, files[i], manifest)
+			}
+		}()
+	}
+	for i := range files {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	var first error
+	failed := 0
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if first == nil {
+			first = fmt.Errorf("writeRenderTree for %q: %v", files[i].path, err)
+		}
+		failed++
+	}
+	if failed > 1 {
+		return fmt.Errorf("%v (and %d other errors)", first, failed-1)
+	}
+	return first
+}
+
+// renderFile is a render that is written to a file at path.
+type renderFile struct {
+	path string
+	r    *render
+}
+
+// createRenderDirs creates the directories of the renderTree |root| under
+// |dest|, and adds the renders of the files to be written to |files|.
+func createRenderDirs(dest string, root *render, files *[]renderFile) error {
 	for part, render := range root.object.(renderTree) {
 		p := path.Join(dest, part)
-		switch render.t {
-		case renderTypeDirectory:
-			// For directories, ensure that the parent directory exists. If it
-			// does not, create it and add a redirect index.html file.
-			if err := os.Mkdir(p, 0755); err != nil && !os.IsExist(err) {
-				return err
-			}
-			// Recurse on its subnodes.
-			if err := writeRenderTree(p, blog, render); err != nil {
-				return err
-			}
-		case renderTypePost:
-			// For posts, just render the content into the template.
-			post := render.object.(*Post)
+		if render.t != renderTypeDirectory {
+			*files = append(*files, renderFile{p, render})
+			continue
+		}
+		// For directories, ensure that the parent directory exists.
+		if err := os.Mkdir(p, 0755); err != nil && !os.IsExist(err) {
+			return err
+		}
+		// Recurse on its subnodes.
+		if err := createRenderDirs(p, render, files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-			// Try to render the post.
-			html, err := RenderPost(post, CreatePageParams(blog, render))
-			if err != nil {
-				return err
-			}
+// writeRenderFile renders a file and writes it, along with its precompressed
+// copies, unless they are up-to-date in the manifest.
+func writeRenderFile(blog *Blog, file renderFile, manifest *buildManifest) error {
+	key := manifest.renderKey(file.path, file.r)
+	if isUpToDate(blog, manifest, file.path, key) {
+		return nil
+	}
 
-			// Try to write the post.
-			f, err := os.Create(p)
-			if err != nil {
-				return err
-			}
-			f.Write(html)
-			f.Close()
-		case renderTypeRedirect:
-			f, err := os.Create(p)
-			if err != nil {
-				return err
-			}
-			fmt.Fprint(f, generateRedirect(render.object.(string)))
-			f.Close()
-		case renderTypeFeed:
-			xml, err := CreateXMLFeed(render.object.(PostList), blog)
-			if err != nil {
-				return err
-			}
+	content, err := renderNode(blog, file.r)
+	if err != nil {
+		return err
+	}
 
-			f, err := os.Create(p)
-			if err != nil {
-				return err
-			}
-			f.Write(xml)
-			f.Close()
-		default:
-			return fmt.Errorf("writeRenderTree for %q: unknown renderType %v", p, render.t)
+	f, err := os.Create(file.path)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	manifest.record(file.path, key)
+	return writePrecompressed(blog, file.path, content, manifest, key)
+}
+
+// isUpToDate returns true if the file at |p| and its precompressed copies are
+// up-to-date in the manifest.
+func isUpToDate(blog *Blog, manifest *buildManifest, p, key string) bool {
+	for _, cp := range precompressedPaths(blog, p) {
+		if !manifest.isUpToDate(cp, key) {
+			return false
+		}
+	}
+	return manifest.isUpToDate(p, key)
+}
+
+// checkOutputDir returns an error if the OutputDir of |blog| contains the blog
+// itself, which rendering would write into and pruning could remove.
+func checkOutputDir(blog *Blog) error {
+	dest, err := filepath.Abs(blog.GetOutputDir())
+	if err != nil {
+		return err
+	}
+	for _, p := range []string{blog.configPath, blog.GetPostsDir()} {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dest, abs)
+		if err != nil {
+			return err
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("output directory %q contains %q", dest, p)
+		}
+	}
+	return nil
+}
+
+// pruneOutputDir removes the files in the OutputDir that were produced by the
+// last render, according to the manifest, but not by this one, unless they are
+// preserved by the configuration. Other files in the OutputDir, and hidden
+// files like .git, are never removed. Directories left empty are removed too.
+// The stale files are reported to |w|, and if |dryRun| is true, they are only
+// listed and are kept in the manifest to be removed by a later render.
+func pruneOutputDir(w io.Writer, blog *Blog, manifest *buildManifest, dryRun bool) error {
+	dest := blog.GetOutputDir()
+
+	var stale []string
+	for rel := range manifest.previous {
+		if _, ok := manifest.Files[rel]; ok || !isPrunable(rel) || blog.isPreserved(rel) {
+			continue
 		}
+		stale = append(stale, rel)
 	}
+	sort.Strings(stale)
 
+	for _, rel := range stale {
+		p := filepath.Join(dest, filepath.FromSlash(rel))
+		if dryRun {
+			fmt.Fprintln(w, "Would remove stale file:", p)
+			manifest.record(p, manifest.previous[rel])
+			continue
+		}
+		fmt.Fprintln(w, "Removing stale file:", p)
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		// Remove the directories that are left empty. Directories that are not
+		// empty fail to be removed, which ends the loop.
+		for dir := filepath.Dir(p); dir != dest && strings.HasPrefix(dir, dest); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
 	return nil
 }
 
-// copyDir dittos the source directory tree to the destination.
-func copyDir(dst, src string) error {
+// isPrunable returns true if the file at |rel|, which is relative to the
+// OutputDir, may be removed: it is inside the OutputDir and it is not hidden
+// or in a hidden directory.
+func isPrunable(rel string) bool {
+	if path.IsAbs(rel) {
+		return false
+	}
+	for _, part := range strings.Split(rel, "/") {
+		if part == ".." || strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	return true
+}
+
+// copyDir dittos the source directory tree to the destination. Files that are
+// up-to-date in the manifest, which may be nil, are not copied again.
+func copyDir(dst, src string, manifest *buildManifest) error {
+	return copyFS(dst, os.DirFS(src), nil, manifest)
+}
+
+// copyFS dittos the tree of the source file system to the destination, like
+// copyDir. If the source does not exist, nothing is copied. If the |blog|,
+// which may be nil, is configured to Precompress its files, their compressed
+// copies are written too.
+func copyFS(dst string, src fs.FS, blog *Blog, manifest *buildManifest) error {
 	// Make sure the destination exists.
 	if err := os.Mkdir(dst, 0755); err != nil && !os.IsExist(err) {
 		return err
 	}
 
-	src = path.Clean(src)
-	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+	return fs.WalkDir(src, ".", func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
+			if p == "." && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
 			return err
 		}
 
-		// Strip off the source directory prefix.
-		newP := path.Join(dst, p[len(src):])
+		newP := path.Join(dst, p)
 
-		if info.IsDir() {
+		if d.IsDir() {
 			if err := os.Mkdir(newP, 0755); !os.IsExist(err) {
 				return err
 			}
-		} else {
-			df, err := os.OpenFile(newP, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
-			if err != nil {
-				return err
-			}
-			defer df.Close()
+			return nil
+		}
 
-			sf, err := os.Open(p)
-			if err != nil {
-				return err
-			}
-			defer sf.Close()
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		key, err := fileKey(src, p, info)
+		if err != nil {
+			return err
+		}
+		key = fmt.Sprintf("%s %v", key, info.Mode())
+		if isUpToDate(blog, manifest, newP, key) {
+			return nil
+		}
 
-			if _, err := io.Copy(df, sf); err != nil {
-				return err
-			}
+		// The built-in files are read-only, but the copies must be writable so
+		// that they can be updated by later renders.
+		df, err := os.OpenFile(newP, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm()|0200)
+		if err != nil {
+			return err
 		}
+		defer df.Close()
 
-		return nil
+		sf, err := src.Open(p)
+		if err != nil {
+			return err
+		}
+		defer sf.Close()
+
+		if _, err := io.Copy(df, sf); err != nil {
+			return err
+		}
+		manifest.record(newP, key)
+
+		if len(precompressedPaths(blog, newP)) == 0 {
+			return nil
+		}
+		content, err := fs.ReadFile(src, p)
+		if err != nil {
+			return err
+		}
+		return writePrecompressed(blog, newP, content, manifest, key)
 	})
 }
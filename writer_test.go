@@ -18,9 +18,12 @@
 package main
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -29,7 +32,7 @@ func TestCopyDir(t *testing.T) {
 	dest := path.Join(os.TempDir(), "TestCopyDir_Dest")
 	defer os.RemoveAll(dest)
 
-	err := copyDir(dest, src)
+	err := copyDir(dest, src, nil)
 	if err != nil {
 		t.Fatalf("Error copying directory: %v", err)
 	}
@@ -68,3 +71,214 @@ func TestCopyDir(t *testing.T) {
 		t.Errorf("Contents of file should be %q, got %q", "Foo", contents)
 	}
 }
+
+// createTestBlog creates a blog in a temporary directory with one post, using
+// the default templates.
+func createTestBlog(t *testing.T) *Blog {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates, err := filepath.Rel(dir, filepath.Join(wd, "templates"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := fmt.Sprintf(`{
+		"ConfigVersion": 2,
+		"Title": "Test",
+		"URL": "https://example.com/",
+		"PostsDir": "./posts",
+		"TemplatesDir": %q,
+		"StaticFilesDir": %q,
+		"OutputDir": "./out"
+	}`, templates, filepath.Join(templates, "static"))
+	if err := os.WriteFile(filepath.Join(dir, ConfigFileName), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "posts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "posts", "post.md"), []byte("~~ Title: Post\n\nHello.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	blog, err := ReadBlog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blog
+}
+
+func TestIncrementalRender(t *testing.T) {
+	blog := createTestBlog(t)
+	if err := WriteStaticBlog(blog); err != nil {
+		t.Fatalf("Unexpected error rendering blog: %v", err)
+	}
+
+	// Change the output, which should not be noticed if the post is unchanged.
+	out := filepath.Join(blog.GetOutputDir(), "post.html")
+	if err := os.WriteFile(out, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteStaticBlog(blog); err != nil {
+		t.Fatalf("Unexpected error rendering blog: %v", err)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "stale" {
+		t.Errorf("Up-to-date post should not be rendered again")
+	}
+
+	*forceRender = true
+	defer func() { *forceRender = false }()
+	if err := WriteStaticBlog(blog); err != nil {
+		t.Fatalf("Unexpected error rendering blog: %v", err)
+	}
+	if data, _ := os.ReadFile(out); !strings.Contains(string(data), "Hello.") {
+		t.Errorf("Post should be rendered again when forced, got %q", data)
+	}
+}
+
+func TestPruneOutputDir(t *testing.T) {
+	blog := createTestBlog(t)
+	out := blog.GetOutputDir()
+
+	// Files that were not produced by Blackblog are never removed.
+	files := []string{
+		"CNAME",
+		".htaccess",
+		".git/config",
+		"by_hand.html",
+		"2012/1/by_hand.html",
+	}
+	for _, f := range files {
+		p := filepath.Join(out, f)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("by hand"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	old := filepath.Join(blog.GetPostsDir(), "old.md")
+	if err := os.WriteFile(old, []byte("~~ Title: Old\n~~ Date: 2 March 2013\n\nOld.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteStaticBlog(blog); err != nil {
+		t.Fatalf("Unexpected error rendering blog: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "2013/3/old.html")); err != nil {
+		t.Fatalf("Expected the old post to be rendered: %v", err)
+	}
+	if err := os.Remove(old); err != nil {
+		t.Fatal(err)
+	}
+
+	var log strings.Builder
+	manifest := loadManifest(out, "")
+	if err := pruneOutputDir(&log, blog, manifest, true); err != nil {
+		t.Fatalf("Unexpected error pruning: %v", err)
+	}
+	if want := "Would remove stale file: " + filepath.Join(out, "2013/3/old.html"); !strings.Contains(log.String(), want) {
+		t.Errorf("Expected %q, got %q", want, log.String())
+	}
+
+	*dryRun = true
+	err := WriteStaticBlog(blog)
+	*dryRun = false
+	if err != nil {
+		t.Fatalf("Unexpected error rendering blog: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "2013/3/old.html")); err != nil {
+		t.Errorf("Stale file should not be removed in a dry run: %v", err)
+	}
+
+	// The stale file is still removed after the dry run.
+	if err := WriteStaticBlog(blog); err != nil {
+		t.Fatalf("Unexpected error rendering blog: %v", err)
+	}
+	expectations := map[string]bool{
+		"2013/3/old.html":     false,
+		"2013/3":              false,
+		"CNAME":               true,
+		".htaccess":           true,
+		".git/config":         true,
+		"by_hand.html":        true,
+		"2012/1/by_hand.html": true,
+		"post.html":           true,
+	}
+	for f, exists := range expectations {
+		_, err := os.Stat(filepath.Join(out, f))
+		if exists && err != nil {
+			t.Errorf("%s should exist: %v", f, err)
+		} else if !exists && err == nil {
+			t.Errorf("%s should have been removed", f)
+		}
+	}
+
+	// An output directory that contains the blog is rejected before anything is
+	// written to it.
+	blog.config.OutputDir = "."
+	if err := WriteStaticBlog(blog); err == nil {
+		t.Errorf("Should not render to an output directory that contains the blog")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(blog.configPath), "index.html")); !os.IsNotExist(err) {
+		t.Errorf("Should not write to an output directory that contains the blog: %v", err)
+	}
+}
+
+func TestParallelRender(t *testing.T) {
+	blog := createTestBlog(t)
+	for i := 1; i <= 8; i++ {
+		post := fmt.Sprintf("~~ Title: Post %d\n~~ Date: 2012-01-%02d\n\nHello %d.\n", i, i, i)
+		if err := os.WriteFile(filepath.Join(blog.GetPostsDir(), fmt.Sprintf("post%d.md", i)), []byte(post), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(jobs int) { *renderJobs = jobs }(*renderJobs)
+	*renderJobs = 4
+	if err := WriteStaticBlog(blog); err != nil {
+		t.Fatalf("Unexpected error rendering blog: %v", err)
+	}
+	for i := 1; i <= 8; i++ {
+		out := filepath.Join(blog.GetOutputDir(), "2012", "1", fmt.Sprintf("post_%d.html", i))
+		if data, err := os.ReadFile(out); err != nil || !strings.Contains(string(data), fmt.Sprintf("Hello %d.", i)) {
+			t.Errorf("Expected %s to be rendered, got %q: %v", out, data, err)
+		}
+	}
+
+	// Every page fails to render with a broken header, and the same error
+	// should be reported each time.
+	useTestTemplates(t, blog, map[string]string{"header.html": "{{.Missing}}"})
+	if err := blog.invalidateTemplates(); err != nil {
+		t.Fatal(err)
+	}
+	*forceRender = true
+	defer func() { *forceRender = false }()
+	first := WriteStaticBlog(blog)
+	if first == nil || !strings.Contains(first.Error(), "other errors") {
+		t.Fatalf("Expected errors rendering with a broken header, got %v", first)
+	}
+	for i := 0; i < 5; i++ {
+		if err := WriteStaticBlog(blog); err == nil || err.Error() != first.Error() {
+			t.Errorf("Expected error %q, got %v", first, err)
+		}
+	}
+}
+
+func TestRenderSkipsBrokenPosts(t *testing.T) {
+	blog := createTestBlog(t)
+	broken := filepath.Join(blog.GetPostsDir(), "broken.md")
+	if err := os.WriteFile(broken, []byte("---\ntitle: [unclosed\n---\nBroken.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteStaticBlog(blog); err != nil {
+		t.Fatalf("A broken post should not fail the render: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(blog.GetOutputDir(), "post.html")); err != nil {
+		t.Errorf("The other posts should be rendered: %v", err)
+	}
+}